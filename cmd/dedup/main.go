@@ -0,0 +1,87 @@
+// dedup 删除点赞和关注中违反唯一索引的重复记录，升级到带唯一索引的版本之前需要先运行，否则服务启动时创建索引会失败。
+// 每组只保留最近更新的一条，并删除受影响目标的物化计数，下次读取时会从明细重新统计
+//
+//	CONFIG_PATH=etc/config.yaml go run ./cmd/dedup -dry-run
+//	CONFIG_PATH=etc/config.yaml go run ./cmd/dedup
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/follow"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/like"
+	"os"
+)
+
+// 需要去重的集合及其唯一索引的名称
+var targets = []struct {
+	collection string
+	indexes    []index.Index
+	name       string
+}{
+	{collection: like.CollectionName, indexes: like.Indexes, name: "target_user_unique"},
+	{collection: follow.CollectionName, indexes: follow.Indexes, name: "target_user_unique"},
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只统计重复记录，不删除")
+	flag.Parse()
+
+	config.Init()
+	// 存在重复数据时创建唯一索引会失败
+	index.Skip()
+
+	ctx := context.Background()
+	aConfig := config.Get()
+	counterMapper := counter.NewMongoMapper()
+
+	for _, t := range targets {
+		var idx *index.Index
+		for i := range t.indexes {
+			if t.indexes[i].Name == t.name {
+				idx = &t.indexes[i]
+			}
+		}
+		if idx == nil {
+			fmt.Fprintf(os.Stderr, "%s没有声明索引%s\n", t.collection, t.name)
+			os.Exit(1)
+		}
+
+		conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, t.collection, aConfig.Cache)
+		keys, deleted, err := index.Dedup(ctx, conn, *idx, *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s去重失败: %v\n", t.collection, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d组重复，删除%d条\n", t.collection, len(keys), deleted)
+
+		if *dryRun {
+			continue
+		}
+		for _, key := range keys {
+			if err = resetCounter(ctx, counterMapper, key); err != nil {
+				fmt.Fprintf(os.Stderr, "删除%v的计数失败: %v\n", key, err)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// resetCounter 删除目标的物化计数，下次读取时从去重后的明细重新统计
+func resetCounter(ctx context.Context, counterMapper counter.IMongoMapper, key bson.M) error {
+	targetId, _ := key["target_id"].(string)
+	targetType, ok := key["target_type"].(int64)
+	if !ok {
+		// 旧数据中的枚举可能以int32存储
+		value, _ := key["target_type"].(int32)
+		targetType = int64(value)
+	}
+	return counterMapper.Delete(ctx, targetId, action.TargetType(targetType))
+}
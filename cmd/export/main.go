@@ -0,0 +1,58 @@
+// export 离线导出用户的全部行为数据，直接读取mongo，不经过rpc
+//
+//	CONFIG_PATH=etc/config.yaml go run ./cmd/export -user <userId> -format csv -o actions.csv
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"meowcloud-action/common/config"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/service"
+	"os"
+)
+
+func main() {
+	userId := flag.String("user", "", "要导出的用户id")
+	format := flag.String("format", consts.ExportFormatJSONL, "导出格式，jsonl或csv")
+	output := flag.String("o", "", "输出文件，默认输出到标准输出")
+	flag.Parse()
+
+	if err := consts.CheckUserId(*userId); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	config.Init()
+	// 只读取数据，不在生产集合上建索引
+	index.Skip()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ctx := context.Background()
+	userDataService := service.NewUserDataService()
+	token := ""
+	for {
+		var err error
+		token, err = userDataService.ExportUserActions(ctx, *userId, *format, token, consts.ExportChunkSize, w)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if token == "" {
+			return
+		}
+	}
+}
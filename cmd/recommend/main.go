@@ -0,0 +1,62 @@
+// recommend 离线预计算"可能认识的人"推荐结果，未指定用户时处理关注数不少于Recommend.HeavyFollowing的用户
+//
+//	CONFIG_PATH=etc/config.yaml go run ./cmd/recommend -types USER,PHOTO
+//	CONFIG_PATH=etc/config.yaml go run ./cmd/recommend -user <userId> -types USER
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/config"
+	"meowcloud-action/service"
+	"os"
+	"strings"
+)
+
+func main() {
+	userId := flag.String("user", "", "只为该用户预计算，默认处理全部重度用户")
+	types := flag.String("types", action.TargetType_USER.String(), "推荐的目标类型，逗号分隔")
+	flag.Parse()
+
+	var targetTypes []action.TargetType
+	for _, name := range strings.Split(*types, ",") {
+		value, ok := action.TargetType_value[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "未知的目标类型%s\n", name)
+			os.Exit(2)
+		}
+		targetTypes = append(targetTypes, action.TargetType(value))
+	}
+
+	config.Init()
+
+	ctx := context.Background()
+	recommendService := service.NewRecommendService()
+
+	userIds := []string{*userId}
+	if *userId == "" {
+		var err error
+		userIds, err = recommendService.GetHeavyUsers(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	failed := 0
+	for _, id := range userIds {
+		for _, targetType := range targetTypes {
+			if err := recommendService.PrecomputeFollowRecommendations(ctx, targetType, id); err != nil {
+				fmt.Fprintf(os.Stderr, "预计算用户%s的%s推荐失败: %v\n", id, targetType, err)
+				failed++
+			}
+		}
+	}
+
+	fmt.Printf("处理%d个用户，失败%d次\n", len(userIds), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/zeromicro/go-zero/core/stores/cache"
 	"os"
+	"time"
 
 	"github.com/zeromicro/go-zero/core/conf"
 	"github.com/zeromicro/go-zero/core/service"
@@ -19,6 +20,51 @@ type Config struct {
 		URL string
 		DB  string
 	}
+	Outbox struct {
+		Broker      string        `json:",default=redis,options=memory|redis"` // memory只用于本地开发，pro模式下拒绝启动
+		Stream      string        `json:",default=meowcloud:action:event"`     // Broker为redis时写入的stream
+		BatchSize   int64         `json:",default=100"`
+		Interval    time.Duration `json:",default=1s"`
+		Lease       time.Duration `json:",default=30s"`  // 投递中的消息超过该时间未确认会被重新投递
+		MaxAttempts int64         `json:",default=10"`   // 超过后转入死信
+		Retention   time.Duration `json:",default=168h"` // 已投递的消息保留的时间
+	}
+	Follow struct {
+		CommonTTL   time.Duration `json:",default=1m"` // 共同关注结果按(viewer, target)缓存的时间
+		CommonLimit int64         `json:",default=3"`  // 默认返回的共同关注用户数
+	}
+	Recommend struct {
+		Limit          int64         `json:",default=20"`   // 默认返回的推荐数
+		SampleSize     int64         `json:",default=500"`  // 只从用户最近关注的SampleSize个用户出发计算，限制单次聚合的规模
+		TTL            time.Duration `json:",default=24h"`  // 离线预计算结果的有效期，过期后回退到在线计算
+		HeavyFollowing int64         `json:",default=1000"` // 关注数不少于该值的用户由离线任务预计算
+	}
+	Reaction struct {
+		Default string   `json:",default=heart"` // 未指定表情的点赞，包括支持表情之前的点赞，都视为该表情
+		Options []string `json:",optional"`      // 可选的表情，Default总是可选
+	}
+	Share struct {
+		IdempotencyWindow time.Duration `json:",default=10m"` // 同一幂等键在该时间内重复提交只记一次分享
+		VisitWindow       time.Duration `json:",default=24h"` // 同一登录用户在该时间内重复打开同一分享链接只记一次访问
+	}
+	RateLimits []RateLimit `json:",optional"` // 未配置的行为不限流
+	Webhook    struct {
+		MaxAttempts int64         `json:",default=8"`   // 超过后转入死信
+		Backoff     time.Duration `json:",default=10s"` // 第n次失败后等待Backoff*2^(n-1)再重试
+		MaxBackoff  time.Duration `json:",default=1h"`
+		Timeout     time.Duration `json:",default=5s"`
+		BatchSize   int64         `json:",default=50"`
+		Interval    time.Duration `json:",default=1s"`
+	}
+}
+
+// RateLimit 按用户和行为计算的令牌桶，TargetType为空时对该行为的全部目标类型生效，同时存在时以指定了TargetType的为准。
+// share_visit按分享码限制匿名访问的计数，超过时链接仍可以打开，只是不再计入访问数
+type RateLimit struct {
+	Action     string  `json:",options=like|follow|share|favorite|share_visit"`
+	TargetType string  `json:",optional"` // 目标类型的名称，如PHOTO
+	Rate       float64 // 每秒补充的令牌数
+	Burst      int64   // 令牌桶容量，即允许的突发次数
 }
 
 func Init() {
@@ -1 +1,41 @@
 package consts
+
+// MaxBatchSize 批量接口一次最多查询的目标数量
+const MaxBatchSize = 500
+
+// 导出用户数据支持的格式
+const (
+	ExportFormatJSONL = "jsonl"
+	ExportFormatCSV   = "csv"
+)
+
+// ExportChunkSize 导出接口每次最多返回的记录数，避免一次把用户的全部数据读入内存
+const ExportChunkSize = 1000
+
+// MaxCommonFollowers 共同关注最多返回的用户数
+const MaxCommonFollowers = 20
+
+// MaxRecommendations 推荐接口一次最多返回的数量，也是离线预计算保存的数量
+const MaxRecommendations = 100
+
+// 分享渠道
+const (
+	ShareChannelUnknown       = "unknown" // 未携带渠道的分享，包括支持渠道之前的历史记录
+	ShareChannelWechatChat    = "wechat_chat"
+	ShareChannelWechatMoments = "wechat_moments"
+	ShareChannelQQ            = "qq"
+	ShareChannelWeibo         = "weibo"
+	ShareChannelLink          = "link" // 复制链接
+)
+
+// MaxShareMetadata 分享附加信息最多的键值对数量
+const MaxShareMetadata = 16
+
+// MaxIdempotencyKey 幂等键的最大长度
+const MaxIdempotencyKey = 128
+
+// MaxFavoriteCollections 每个用户最多的收藏夹数量
+const MaxFavoriteCollections = 100
+
+// MaxCollectionName 收藏夹名称的最大字数
+const MaxCollectionName = 30
@@ -3,6 +3,8 @@ package consts
 import (
 	"errors"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"strings"
+	"unicode/utf8"
 )
 
 var UserNotExist = errors.New("用户不存在")
@@ -11,6 +13,34 @@ var LikeNotExist = errors.New("点赞不存在")
 var FollowNotExist = errors.New("关注不存在")
 var RepeatFollow = errors.New("请勿重复关注")
 var TryAgain = errors.New("操作失败，请重试")
+var BatchTooLarge = errors.New("批量查询数量过多")
+var TargetNotExist = errors.New("目标不存在")
+var FormatNotSupport = errors.New("不支持的导出格式")
+var InvalidExportToken = errors.New("导出token无效")
+var WebhookNotExist = errors.New("回调不存在")
+var InvalidWebhookURL = errors.New("回调地址无效")
+var DeadLetterNotExist = errors.New("死信不存在")
+var Blocked = errors.New("你们之间存在拉黑关系，无法操作")
+var RepeatBlock = errors.New("请勿重复拉黑")
+var BlockNotExist = errors.New("拉黑不存在")
+var BlockSelf = errors.New("不能拉黑自己")
+var RepeatFollowRequest = errors.New("已发送过关注请求，请等待对方处理")
+var FollowRequestNotExist = errors.New("关注请求不存在")
+var TooManyRequests = errors.New("操作过于频繁，请稍后再试")
+var ChannelNotSupport = errors.New("不支持的分享渠道")
+var MetadataTooLarge = errors.New("分享附加信息过多")
+var ShareCodeNotExist = errors.New("分享链接不存在")
+var ConversionNotSupport = errors.New("不支持的转化类型")
+var IdempotencyKeyTooLong = errors.New("幂等键过长")
+var ReactionNotSupport = errors.New("不支持的表情")
+var RepeatReaction = errors.New("请勿重复使用相同的表情")
+var RepeatFavorite = errors.New("请勿重复收藏")
+var FavoriteNotExist = errors.New("收藏不存在")
+var CollectionNotExist = errors.New("收藏夹不存在")
+var RepeatCollectionName = errors.New("收藏夹名称已存在")
+var InvalidCollectionName = errors.New("收藏夹名称不能为空或过长")
+var TooManyCollections = errors.New("收藏夹数量已达上限")
+var InvalidCollectionOrder = errors.New("收藏夹排序需要包含全部收藏夹")
 
 func CheckUserMeta(meta *basic.UserMeta) error {
 
@@ -29,3 +59,55 @@ func CheckUserId(userId string) error {
 
 	return nil
 }
+
+func CheckTargetId(targetId string) error {
+
+	if targetId == "" {
+		return TargetNotExist
+	}
+
+	return nil
+}
+
+func CheckTargetIds(targetIds []string) error {
+
+	if len(targetIds) > MaxBatchSize {
+		return BatchTooLarge
+	}
+
+	return nil
+}
+
+func CheckShareChannel(channel string, metadata map[string]string) error {
+
+	switch channel {
+	case ShareChannelUnknown, ShareChannelWechatChat, ShareChannelWechatMoments, ShareChannelQQ, ShareChannelWeibo, ShareChannelLink:
+	default:
+		return ChannelNotSupport
+	}
+
+	if len(metadata) > MaxShareMetadata {
+		return MetadataTooLarge
+	}
+
+	return nil
+}
+
+func CheckIdempotencyKey(key string) error {
+
+	if len(key) > MaxIdempotencyKey {
+		return IdempotencyKeyTooLong
+	}
+
+	return nil
+}
+
+func CheckCollectionName(name string) error {
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxCollectionName {
+		return InvalidCollectionName
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+type GetActionSummaryReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"` // 未登录时为空，此时不返回用户自身的状态
+}
+
+type GetActionSummaryResp struct {
+	LikedCount    int64 `json:"likedCount,omitempty"`
+	SharedCount   int64 `json:"sharedCount,omitempty"`
+	FollowedCount int64 `json:"followedCount,omitempty"`
+	Liked         bool  `json:"liked,omitempty"`
+	Shared        bool  `json:"shared,omitempty"`
+	Followed      bool  `json:"followed,omitempty"`
+}
@@ -0,0 +1,49 @@
+package dto
+
+import "github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+
+// DoBlockReq 当前用户拉黑TargetId用户，会同时取消双方之间的关注
+type DoBlockReq struct {
+	TargetId string          `json:"targetId,omitempty"`
+	User     *basic.UserMeta `json:"user,omitempty"`
+}
+
+type DoBlockResp struct {
+}
+
+type CancelBlockReq struct {
+	TargetId string          `json:"targetId,omitempty"`
+	User     *basic.UserMeta `json:"user,omitempty"`
+}
+
+type CancelBlockResp struct {
+}
+
+type GetBlockedReq struct {
+	TargetId string          `json:"targetId,omitempty"`
+	User     *basic.UserMeta `json:"user,omitempty"`
+}
+
+type GetBlockedResp struct {
+	Blocked bool `json:"blocked,omitempty"`
+}
+
+type Block struct {
+	Id       string `json:"id,omitempty"`
+	TargetId string `json:"targetId,omitempty"`
+	UserId   string `json:"userId,omitempty"`
+	CreateAt int64  `json:"createAt,omitempty"`
+}
+
+// GetBlockedUsersReq 分页查询当前用户拉黑的用户
+type GetBlockedUsersReq struct {
+	User             *basic.UserMeta          `json:"user,omitempty"`
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type GetBlockedUsersResp struct {
+	Blocks  []*Block `json:"blocks,omitempty"`
+	Total   int64    `json:"total,omitempty"`
+	Token   string   `json:"token,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
@@ -0,0 +1,2 @@
+// Package dto 存放尚未加入service-idl的接口的请求与响应，IDL更新后应迁移到kitex_gen
+package dto
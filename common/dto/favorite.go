@@ -0,0 +1,147 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+// DoFavoriteReq 收藏目标到CollectionId对应的收藏夹，为空时放入未分组。
+// 每个用户对每个目标只有一条收藏，不能同时放在多个收藏夹中，已收藏时移动到该收藏夹
+type DoFavoriteReq struct {
+	TargetId     string            `json:"targetId,omitempty"`
+	TargetType   action.TargetType `json:"targetType,omitempty"`
+	CollectionId string            `json:"collectionId,omitempty"`
+	User         *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type DoFavoriteResp struct {
+	Moved                bool   `json:"moved,omitempty"`                // 为true表示原先已收藏，本次只是移动了收藏夹
+	PreviousCollectionId string `json:"previousCollectionId,omitempty"` // 移动前的收藏夹，未分组时为空
+}
+
+type CancelFavoriteReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type CancelFavoriteResp struct {
+}
+
+type GetFavoritedReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type GetFavoritedResp struct {
+	Favorited    bool   `json:"favorited,omitempty"`
+	CollectionId string `json:"collectionId,omitempty"` // 收藏所在的唯一收藏夹，未分组时为空
+}
+
+type BatchGetFavoritedReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type BatchGetFavoritedResp struct {
+	Favorited map[string]bool `json:"favorited,omitempty"` // key为targetId
+}
+
+type GetFavoritedCountReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+type GetFavoritedCountResp struct {
+	Count int64 `json:"count,omitempty"`
+}
+
+type BatchGetFavoritedCountReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+type BatchGetFavoritedCountResp struct {
+	Counts map[string]int64 `json:"counts,omitempty"` // key为targetId
+}
+
+type FavoriteCollection struct {
+	Id       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Position int64  `json:"position,omitempty"`
+	Count    int64  `json:"count,omitempty"` // 收藏夹中的收藏数
+	CreateAt int64  `json:"createAt,omitempty"`
+}
+
+// CreateFavoriteCollectionReq 新建的收藏夹排在最后
+type CreateFavoriteCollectionReq struct {
+	Name string          `json:"name,omitempty"`
+	User *basic.UserMeta `json:"user,omitempty"`
+}
+
+type CreateFavoriteCollectionResp struct {
+	Collection *FavoriteCollection `json:"collection,omitempty"`
+}
+
+type RenameFavoriteCollectionReq struct {
+	CollectionId string          `json:"collectionId,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	User         *basic.UserMeta `json:"user,omitempty"`
+}
+
+type RenameFavoriteCollectionResp struct {
+}
+
+// DeleteFavoriteCollectionReq 删除收藏夹，其中的收藏移动到未分组
+type DeleteFavoriteCollectionReq struct {
+	CollectionId string          `json:"collectionId,omitempty"`
+	User         *basic.UserMeta `json:"user,omitempty"`
+}
+
+type DeleteFavoriteCollectionResp struct {
+	Moved int64 `json:"moved,omitempty"` // 移动到未分组的收藏数
+}
+
+// ReorderFavoriteCollectionsReq CollectionIds为调整后的顺序，需要包含当前用户的全部收藏夹
+type ReorderFavoriteCollectionsReq struct {
+	CollectionIds []string        `json:"collectionIds,omitempty"`
+	User          *basic.UserMeta `json:"user,omitempty"`
+}
+
+type ReorderFavoriteCollectionsResp struct {
+}
+
+type GetFavoriteCollectionsReq struct {
+	User *basic.UserMeta `json:"user,omitempty"`
+}
+
+type GetFavoriteCollectionsResp struct {
+	Collections []*FavoriteCollection `json:"collections,omitempty"`
+	Ungrouped   int64                 `json:"ungrouped,omitempty"` // 未分组的收藏数
+}
+
+// Favorite 用户对目标的收藏，每个用户对每个目标只有一条，CollectionId为其所在的收藏夹
+type Favorite struct {
+	Id           string            `json:"id,omitempty"`
+	TargetId     string            `json:"targetId,omitempty"`
+	TargetType   action.TargetType `json:"targetType,omitempty"`
+	UserId       string            `json:"userId,omitempty"`
+	CollectionId string            `json:"collectionId,omitempty"`
+	CreateAt     int64             `json:"createAt,omitempty"`
+}
+
+// GetCollectionFavoritesReq 分页查询收藏夹中的内容，CollectionId为空时查询未分组的收藏
+type GetCollectionFavoritesReq struct {
+	CollectionId     string                   `json:"collectionId,omitempty"`
+	User             *basic.UserMeta          `json:"user,omitempty"`
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type GetCollectionFavoritesResp struct {
+	Favorites []*Favorite `json:"favorites,omitempty"`
+	Total     int64       `json:"total,omitempty"`
+	Token     string      `json:"token,omitempty"`
+	HasMore   bool        `json:"hasMore,omitempty"`
+}
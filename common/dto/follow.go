@@ -0,0 +1,99 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+type BatchGetFollowedReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type BatchGetFollowedResp struct {
+	Followed map[string]bool `json:"followed,omitempty"` // key为targetId
+}
+
+type BatchGetFollowedCountReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+type BatchGetFollowedCountResp struct {
+	Counts map[string]int64 `json:"counts,omitempty"` // key为targetId
+}
+
+// Follow 在action.Action_Follow的基础上附带是否互相关注，仅TargetType为USER时有意义
+type Follow struct {
+	Id         string            `json:"id,omitempty"`
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	UserId     string            `json:"userId,omitempty"`
+	CreateAt   int64             `json:"createAt,omitempty"`
+	Mutual     bool              `json:"mutual,omitempty"`
+}
+
+type GetFollowedUsersResp struct {
+	Follows []*Follow `json:"follows,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	Token   string    `json:"token,omitempty"`
+	HasMore bool      `json:"hasMore,omitempty"`
+}
+
+type GetUserFollowedResp struct {
+	Follows []*Follow `json:"follows,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	Token   string    `json:"token,omitempty"`
+	HasMore bool      `json:"hasMore,omitempty"`
+}
+
+// GetMutualFollowedReq 查询当前用户与TargetId用户之间的关注关系
+type GetMutualFollowedReq struct {
+	TargetId string          `json:"targetId,omitempty"`
+	User     *basic.UserMeta `json:"user,omitempty"`
+}
+
+type GetMutualFollowedResp struct {
+	Followed     bool `json:"followed,omitempty"`     // 当前用户关注了对方
+	FollowedBack bool `json:"followedBack,omitempty"` // 对方关注了当前用户
+	Mutual       bool `json:"mutual,omitempty"`
+}
+
+// GetMutualFollowsReq 分页查询与当前用户互相关注的用户
+type GetMutualFollowsReq struct {
+	User             *basic.UserMeta          `json:"user,omitempty"`
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type GetMutualFollowsResp struct {
+	Follows []*Follow `json:"follows,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	Token   string    `json:"token,omitempty"`
+	HasMore bool      `json:"hasMore,omitempty"`
+}
+
+// GetNotFollowedBackReq 分页查询当前用户与其他用户之间的单向关注
+type GetNotFollowedBackReq struct {
+	User             *basic.UserMeta          `json:"user,omitempty"`
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type GetNotFollowedBackResp struct {
+	Follows []*Follow `json:"follows,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	Token   string    `json:"token,omitempty"`
+	HasMore bool      `json:"hasMore,omitempty"`
+}
+
+// GetCommonFollowersReq 查询当前用户关注的人中有哪些也关注了TargetId用户，Limit为返回的用户数
+type GetCommonFollowersReq struct {
+	TargetId string          `json:"targetId,omitempty"`
+	Limit    int64           `json:"limit,omitempty"`
+	User     *basic.UserMeta `json:"user,omitempty"`
+}
+
+type GetCommonFollowersResp struct {
+	UserIds []string `json:"userIds,omitempty"`
+	Total   int64    `json:"total,omitempty"`
+}
@@ -0,0 +1,59 @@
+package dto
+
+import "github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+
+// SetFollowPrivacyReq 设置当前用户是否为私密账号，私密账号被关注时会产生待处理的关注请求
+type SetFollowPrivacyReq struct {
+	Private bool            `json:"private,omitempty"`
+	User    *basic.UserMeta `json:"user,omitempty"`
+}
+
+type SetFollowPrivacyResp struct {
+}
+
+type GetFollowPrivacyReq struct {
+	TargetId string `json:"targetId,omitempty"`
+}
+
+type GetFollowPrivacyResp struct {
+	Private bool `json:"private,omitempty"`
+}
+
+// FollowRequest UserId请求关注TargetId
+type FollowRequest struct {
+	Id       string `json:"id,omitempty"`
+	TargetId string `json:"targetId,omitempty"`
+	UserId   string `json:"userId,omitempty"`
+	CreateAt int64  `json:"createAt,omitempty"`
+}
+
+// GetFollowRequestsReq 分页查询当前用户收到或发出的待处理请求
+type GetFollowRequestsReq struct {
+	User             *basic.UserMeta          `json:"user,omitempty"`
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type GetFollowRequestsResp struct {
+	Requests []*FollowRequest `json:"requests,omitempty"`
+	Total    int64            `json:"total,omitempty"`
+	Token    string           `json:"token,omitempty"`
+	HasMore  bool             `json:"hasMore,omitempty"`
+}
+
+// HandleFollowRequestReq 当前用户通过或拒绝UserId发来的请求
+type HandleFollowRequestReq struct {
+	UserId string          `json:"userId,omitempty"`
+	User   *basic.UserMeta `json:"user,omitempty"`
+}
+
+type HandleFollowRequestResp struct {
+}
+
+// WithdrawFollowRequestReq 当前用户撤回发给TargetId的请求
+type WithdrawFollowRequestReq struct {
+	TargetId string          `json:"targetId,omitempty"`
+	User     *basic.UserMeta `json:"user,omitempty"`
+}
+
+type WithdrawFollowRequestResp struct {
+}
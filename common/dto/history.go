@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+type ActionEvent struct {
+	Id         string            `json:"id,omitempty"`
+	Action     string            `json:"action,omitempty"` // like、follow、share
+	Op         string            `json:"op,omitempty"`     // do、cancel
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	UserId     string            `json:"userId,omitempty"`
+	CreateAt   int64             `json:"createAt,omitempty"`
+}
+
+type GetUserHistoryReq struct {
+	Action           string                   `json:"action,omitempty"` // 为空时返回全部种类
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+	User             *basic.UserMeta          `json:"user,omitempty"`
+}
+
+type GetUserHistoryResp struct {
+	Events  []*ActionEvent `json:"events,omitempty"`
+	Token   string         `json:"token,omitempty"`   // 请求相邻页时作为LastToken传入，为空表示当前页没有数据
+	HasMore bool           `json:"hasMore,omitempty"` // 沿当前方向继续翻页是否还有数据
+}
+
+type GetTargetHistoryReq struct {
+	TargetId         string                   `json:"targetId,omitempty"`
+	TargetType       action.TargetType        `json:"targetType,omitempty"`
+	Action           string                   `json:"action,omitempty"` // 为空时返回全部种类
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type GetTargetHistoryResp struct {
+	Events  []*ActionEvent `json:"events,omitempty"`
+	Token   string         `json:"token,omitempty"`   // 请求相邻页时作为LastToken传入，为空表示当前页没有数据
+	HasMore bool           `json:"hasMore,omitempty"` // 沿当前方向继续翻页是否还有数据
+}
@@ -0,0 +1,40 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+type BatchGetLikedReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type BatchGetLikedResp struct {
+	Liked map[string]bool `json:"liked,omitempty"` // key为targetId
+}
+
+type BatchGetLikedCountReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+type BatchGetLikedCountResp struct {
+	Counts map[string]int64 `json:"counts,omitempty"` // key为targetId
+}
+
+// GetLikedUsersResp 在action.GetLikedUsersResp的基础上返回游标分页的token
+type GetLikedUsersResp struct {
+	Likes   []*action.Action_Like `json:"likes,omitempty"`
+	Total   int64                 `json:"total,omitempty"`
+	Token   string                `json:"token,omitempty"`
+	HasMore bool                  `json:"hasMore,omitempty"`
+}
+
+type GetUserLikedResp struct {
+	Likes   []*action.Action_Like `json:"likes,omitempty"`
+	Total   int64                 `json:"total,omitempty"`
+	Token   string                `json:"token,omitempty"`
+	HasMore bool                  `json:"hasMore,omitempty"`
+}
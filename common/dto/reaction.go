@@ -0,0 +1,80 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+// DoReactionReq 对目标使用表情，每个用户对同一目标只有一个表情，已有表情时替换，Reaction为空时使用默认表情
+type DoReactionReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	Reaction   string            `json:"reaction,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type DoReactionResp struct {
+	Previous string `json:"previous,omitempty"` // 被替换的表情，原先没有表情时为空
+}
+
+type CancelReactionReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type CancelReactionResp struct {
+}
+
+type GetReactionReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type GetReactionResp struct {
+	Reaction string `json:"reaction,omitempty"` // 未使用表情时为空
+}
+
+type GetReactionCountsReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+// GetReactionCountsResp Counts的key为表情，包含全部可选表情，Total为各表情之和
+type GetReactionCountsResp struct {
+	Counts map[string]int64 `json:"counts,omitempty"`
+	Total  int64            `json:"total,omitempty"`
+}
+
+type Reaction struct {
+	Id         string            `json:"id,omitempty"`
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	UserId     string            `json:"userId,omitempty"`
+	Reaction   string            `json:"reaction,omitempty"`
+	CreateAt   int64             `json:"createAt,omitempty"`
+}
+
+// GetReactedUsersReq 分页查询对目标使用了某个表情的用户，Reaction为空时查询默认表情
+type GetReactedUsersReq struct {
+	TargetId         string                   `json:"targetId,omitempty"`
+	TargetType       action.TargetType        `json:"targetType,omitempty"`
+	Reaction         string                   `json:"reaction,omitempty"`
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type GetReactedUsersResp struct {
+	Reactions []*Reaction `json:"reactions,omitempty"`
+	Total     int64       `json:"total,omitempty"`
+	Token     string      `json:"token,omitempty"`
+	HasMore   bool        `json:"hasMore,omitempty"`
+}
+
+type GetReactionOptionsReq struct {
+}
+
+type GetReactionOptionsResp struct {
+	Default string   `json:"default,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
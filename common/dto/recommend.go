@@ -0,0 +1,24 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+// GetFollowRecommendationsReq 按"关注的人也关注了"为当前用户推荐TargetType类型的关注对象
+type GetFollowRecommendationsReq struct {
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	Limit      int64             `json:"limit,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+// Recommendation Score为当前用户关注的人中关注了TargetId的人数
+type Recommendation struct {
+	TargetId string `json:"targetId,omitempty"`
+	Score    int64  `json:"score,omitempty"`
+}
+
+type GetFollowRecommendationsResp struct {
+	Recommendations []*Recommendation `json:"recommendations,omitempty"`
+	Precomputed     bool              `json:"precomputed,omitempty"` // 结果来自离线预计算
+}
@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+type BatchGetSharedReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	User       *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type BatchGetSharedResp struct {
+	Shared map[string]bool `json:"shared,omitempty"` // key为targetId
+}
+
+type BatchGetSharedCountReq struct {
+	TargetIds  []string          `json:"targetIds,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+// BatchGetSharedCountResp Counts为分享总次数，Sharers为分享过的不同用户数
+type BatchGetSharedCountResp struct {
+	Counts  map[string]int64 `json:"counts,omitempty"`  // key为targetId
+	Sharers map[string]int64 `json:"sharers,omitempty"` // key为targetId
+}
+
+// DoShareReq 在action.DoShareReq的基础上携带分享渠道和附加信息，Channel为空表示未知渠道
+// IdempotencyKey由客户端为每次分享生成，重试时沿用，窗口期内重复提交不会重复记录
+type DoShareReq struct {
+	TargetId       string            `json:"targetId,omitempty"`
+	TargetType     action.TargetType `json:"targetType,omitempty"`
+	Channel        string            `json:"channel,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	IdempotencyKey string            `json:"idempotencyKey,omitempty"`
+	User           *basic.UserMeta   `json:"user,omitempty"`
+}
+
+type DoShareResp struct {
+	ShareId  string `json:"shareId,omitempty"`
+	Code     string `json:"code,omitempty"`     // 分享链接中的短码
+	Replayed bool   `json:"replayed,omitempty"` // 为true表示是重复提交，返回的是第一次分享的结果
+}
+
+type GetSharedCountBreakdownReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+// GetSharedCountBreakdownResp Count与GetSharedCount一致，Sharers为分享过的不同用户数，Channels为各渠道的分享数，key为渠道
+type GetSharedCountBreakdownResp struct {
+	Count    int64            `json:"count,omitempty"`
+	Sharers  int64            `json:"sharers,omitempty"`
+	Channels map[string]int64 `json:"channels,omitempty"`
+}
+
+// GetSharedUsersResp 在action.GetSharedUsersResp的基础上返回游标分页的token
+type GetSharedUsersResp struct {
+	Shares  []*action.Action_Share `json:"shares,omitempty"`
+	Total   int64                  `json:"total,omitempty"`
+	Token   string                 `json:"token,omitempty"`
+	HasMore bool                   `json:"hasMore,omitempty"`
+}
+
+type GetUserSharedResp struct {
+	Shares  []*action.Action_Share `json:"shares,omitempty"`
+	Total   int64                  `json:"total,omitempty"`
+	Token   string                 `json:"token,omitempty"`
+	HasMore bool                   `json:"hasMore,omitempty"`
+}
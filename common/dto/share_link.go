@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+// ResolveShareCodeReq 打开分享链接时解析短码并计入一次访问，未登录时User为空
+type ResolveShareCodeReq struct {
+	Code string          `json:"code,omitempty"`
+	User *basic.UserMeta `json:"user,omitempty"`
+}
+
+type ResolveShareCodeResp struct {
+	ShareId    string            `json:"shareId,omitempty"`
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	SharerId   string            `json:"sharerId,omitempty"`
+	Channel    string            `json:"channel,omitempty"`
+}
+
+// CreditShareConversionReq 把当前用户的点赞计入分享者，Kind只能为like，每个用户在每次分享中只计入一次。
+// 注册由账号服务通过RecordShareSignUp计入
+type CreditShareConversionReq struct {
+	Code string          `json:"code,omitempty"`
+	Kind string          `json:"kind,omitempty"`
+	User *basic.UserMeta `json:"user,omitempty"`
+}
+
+type CreditShareConversionResp struct {
+	Credited bool `json:"credited,omitempty"` // 重复计入或分享者本人时为false
+}
+
+// RecordShareSignUpReq 内部接口，由账号服务在用户通过分享链接注册后调用，不对客户端开放
+type RecordShareSignUpReq struct {
+	Code   string `json:"code,omitempty"`
+	UserId string `json:"userId,omitempty"`
+}
+
+type RecordShareSignUpResp struct {
+	Credited bool `json:"credited,omitempty"` // 已经计入过或分享者本人时为false
+}
+
+type ShareStats struct {
+	Visits  int64 `json:"visits,omitempty"`
+	SignUps int64 `json:"signUps,omitempty"`
+	Likes   int64 `json:"likes,omitempty"`
+}
+
+// GetShareStatsReq 查询当前用户某次分享带来的访问和转化
+type GetShareStatsReq struct {
+	Code string          `json:"code,omitempty"`
+	User *basic.UserMeta `json:"user,omitempty"`
+}
+
+type GetShareStatsResp struct {
+	Stats *ShareStats `json:"stats,omitempty"`
+}
+
+// GetUserShareStatsReq 查询当前用户全部分享的汇总
+type GetUserShareStatsReq struct {
+	User *basic.UserMeta `json:"user,omitempty"`
+}
+
+type GetUserShareStatsResp struct {
+	Shares int64       `json:"shares,omitempty"`
+	Stats  *ShareStats `json:"stats,omitempty"`
+}
@@ -0,0 +1,37 @@
+package dto
+
+import "github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+
+type DeleteTargetActionsReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+	DryRun     bool              `json:"dryRun,omitempty"` // 为true时只统计不删除
+}
+
+// DeleteTargetActionsResp 各集合中指向目标的记录数，DryRun时为将要删除的数量。
+// 早期写入的webhook投递和死信没有目标字段，不会被删除
+type DeleteTargetActionsResp struct {
+	Likes             int64 `json:"likes,omitempty"`
+	Follows           int64 `json:"follows,omitempty"`
+	Shares            int64 `json:"shares,omitempty"` // 包括分享码
+	Favorites         int64 `json:"favorites,omitempty"`
+	Events            int64 `json:"events,omitempty"`            // 行为历史
+	Conversions       int64 `json:"conversions,omitempty"`       // 通过目标的分享链接产生的访问和转化
+	Recommendations   int64 `json:"recommendations,omitempty"`   // 推荐了目标的预计算结果，只移除目标本身
+	OutboxMessages    int64 `json:"outboxMessages,omitempty"`    // 尚未投递或已转入死信的消息
+	WebhookDeliveries int64 `json:"webhookDeliveries,omitempty"` // 待投递的webhook
+	DeadLetters       int64 `json:"deadLetters,omitempty"`
+}
+
+type ReconcileCountsReq struct {
+	TargetId   string            `json:"targetId,omitempty"`
+	TargetType action.TargetType `json:"targetType,omitempty"`
+}
+
+// ReconcileCountsResp 从明细重新统计后写入的计数
+type ReconcileCountsResp struct {
+	Likes     int64 `json:"likes,omitempty"`
+	Follows   int64 `json:"follows,omitempty"`
+	Shares    int64 `json:"shares,omitempty"`
+	Favorites int64 `json:"favorites,omitempty"`
+}
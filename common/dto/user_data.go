@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+type EraseUserActionsReq struct {
+	UserId string `json:"userId,omitempty"`
+}
+
+// EraseUserActionsResp 本次调用中各集合被删除的记录数，Received为其他用户指向该用户的行为，
+// 包括该用户收到的关注和关注请求。调用失败后重新调用只会统计剩余的记录
+type EraseUserActionsResp struct {
+	Likes             int64                    `json:"likes,omitempty"`
+	Follows           int64                    `json:"follows,omitempty"` // 包括发出的关注请求
+	Shares            int64                    `json:"shares,omitempty"`  // 包括分享码
+	Favorites         int64                    `json:"favorites,omitempty"`
+	Events            int64                    `json:"events,omitempty"`            // 用户产生的或以用户为目标的事件
+	Conversions       int64                    `json:"conversions,omitempty"`       // 作为访问者或分享者的访问和转化
+	OutboxMessages    int64                    `json:"outboxMessages,omitempty"`    // 用户产生的或指向用户的消息
+	WebhookDeliveries int64                    `json:"webhookDeliveries,omitempty"` // 用户产生的待投递的webhook
+	DeadLetters       int64                    `json:"deadLetters,omitempty"`
+	Received          *DeleteTargetActionsResp `json:"received,omitempty"`
+}
+
+// ActionRecord 导出时的一条行为记录，时间为unix秒，未取消时DeleteAt为0
+type ActionRecord struct {
+	Action     string            `json:"action"`
+	TargetId   string            `json:"targetId"`
+	TargetType action.TargetType `json:"targetType"`
+	UserId     string            `json:"userId"`
+	IsCancel   bool              `json:"isCancel"`
+	CreateAt   int64             `json:"createAt"`
+	UpdateAt   int64             `json:"updateAt"`
+	DeleteAt   int64             `json:"deleteAt"`
+	Channel    string            `json:"channel,omitempty"`  // 只有分享有渠道
+	Reaction   string            `json:"reaction,omitempty"` // 只有点赞有表情，为空表示默认表情
+}
+
+// ExportUserActionsReq 分段导出，每次最多返回consts.ExportChunkSize条，Token为上次响应中的Token，为空时从头开始
+type ExportUserActionsReq struct {
+	Format string          `json:"format,omitempty"` // jsonl或csv，默认jsonl，分段之间不能改变
+	User   *basic.UserMeta `json:"user,omitempty"`
+	Token  string          `json:"token,omitempty"`
+}
+
+// ExportUserActionsResp 把各段的Data依次拼接即为完整的导出文件，csv的表头只在第一段中
+type ExportUserActionsResp struct {
+	Format string `json:"format,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+	Token  string `json:"token,omitempty"` // 为空表示已经导出完毕
+}
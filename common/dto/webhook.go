@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+)
+
+// Webhook 回调订阅，Topics形如like.do、follow.cancel，Topics和TargetTypes为空表示不过滤
+type Webhook struct {
+	Id          string              `json:"id,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Topics      []string            `json:"topics,omitempty"`
+	TargetTypes []action.TargetType `json:"targetTypes,omitempty"`
+	CreateAt    int64               `json:"createAt,omitempty"`
+}
+
+type CreateWebhookReq struct {
+	URL         string              `json:"url,omitempty"`
+	Topics      []string            `json:"topics,omitempty"`
+	TargetTypes []action.TargetType `json:"targetTypes,omitempty"`
+}
+
+// CreateWebhookResp Secret只在创建时返回一次，用于校验X-Meowcloud-Signature
+type CreateWebhookResp struct {
+	Webhook *Webhook `json:"webhook,omitempty"`
+	Secret  string   `json:"secret,omitempty"`
+}
+
+type DeleteWebhookReq struct {
+	Id string `json:"id,omitempty"`
+}
+
+type DeleteWebhookResp struct {
+}
+
+type ListWebhooksReq struct {
+}
+
+type ListWebhooksResp struct {
+	Webhooks []*Webhook `json:"webhooks,omitempty"`
+}
+
+// DeadLetter 多次重试仍失败的投递
+type DeadLetter struct {
+	Id        string `json:"id,omitempty"`
+	WebhookId string `json:"webhookId,omitempty"`
+	EventId   string `json:"eventId,omitempty"`
+	Topic     string `json:"topic,omitempty"`
+	Payload   string `json:"payload,omitempty"`
+	Attempts  int64  `json:"attempts,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+	CreateAt  int64  `json:"createAt,omitempty"`
+}
+
+type ListDeadLettersReq struct {
+	PaginationOption *basic.PaginationOptions `json:"paginationOption,omitempty"`
+}
+
+type ListDeadLettersResp struct {
+	DeadLetters []*DeadLetter `json:"deadLetters,omitempty"`
+	Token       string        `json:"token,omitempty"`
+	HasMore     bool          `json:"hasMore,omitempty"`
+}
+
+// ReplayDeadLetterReq 把死信重新放回投递队列，重试次数从头计算
+type ReplayDeadLetterReq struct {
+	Id string `json:"id,omitempty"`
+}
+
+type ReplayDeadLetterResp struct {
+}
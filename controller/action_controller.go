@@ -1,15 +1,105 @@
 package controller
 
+import (
+	"context"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/mr"
+	"meowcloud-action/common/dto"
+)
+
 type ActionController struct {
 	IFollowController
 	ILikeController
 	IShareController
+	IHistoryController
+	IUserDataController
+	ITargetController
+	IWebhookController
+	IRecommendController
+	IBlockController
+	IFollowRequestController
+	IShareLinkController
+	IReactionController
+	IFavoriteController
 }
 
 func NewActionController() *ActionController {
 	return &ActionController{
-		IFollowController: NewFollowController(),
-		ILikeController:   NewLikeController(),
-		IShareController:  NewShareController(),
+		IFollowController:        NewFollowController(),
+		ILikeController:          NewLikeController(),
+		IShareController:         NewShareController(),
+		IHistoryController:       NewHistoryController(),
+		IUserDataController:      NewUserDataController(),
+		ITargetController:        NewTargetController(),
+		IWebhookController:       NewWebhookController(),
+		IRecommendController:     NewRecommendController(),
+		IBlockController:         NewBlockController(),
+		IFollowRequestController: NewFollowRequestController(),
+		IShareLinkController:     NewShareLinkController(),
+		IReactionController:      NewReactionController(),
+		IFavoriteController:      NewFavoriteController(),
+	}
+}
+
+// GetActionSummary 并发查询目标的点赞、分享、关注数量，以及当前用户对目标的状态
+func (controller *ActionController) GetActionSummary(ctx context.Context, req *dto.GetActionSummaryReq) (*dto.GetActionSummaryResp, error) {
+	resp := &dto.GetActionSummaryResp{}
+
+	fns := []func() error{
+		func() error {
+			res, err := controller.GetLikedCount(ctx, &action.GetLikedCountReq{TargetId: req.TargetId, TargetType: req.TargetType})
+			if err == nil {
+				resp.LikedCount = res.Count
+			}
+			return err
+		},
+		func() error {
+			res, err := controller.GetSharedCount(ctx, &action.GetSharedCountReq{TargetId: req.TargetId, TargetType: req.TargetType})
+			if err == nil {
+				resp.SharedCount = res.Count
+			}
+			return err
+		},
+		func() error {
+			res, err := controller.GetFollowedCount(ctx, &action.GetFollowedCountReq{TargetId: req.TargetId, TargetType: req.TargetType})
+			if err == nil {
+				resp.FollowedCount = res.Count
+			}
+			return err
+		},
 	}
+
+	// 未登录时只返回数量
+	if req.User != nil {
+		fns = append(fns,
+			func() error {
+				res, err := controller.GetLiked(ctx, &action.GetLikedReq{TargetId: req.TargetId, TargetType: req.TargetType, User: req.User})
+				if err == nil {
+					resp.Liked = res.Liked
+				}
+				return err
+			},
+			func() error {
+				res, err := controller.GetShared(ctx, &action.GetSharedReq{TargetId: req.TargetId, TargetType: req.TargetType, User: req.User})
+				if err == nil {
+					resp.Shared = res.Shared
+				}
+				return err
+			},
+			func() error {
+				res, err := controller.GetFollowed(ctx, &action.GetFollowedReq{TargetId: req.TargetId, TargetType: req.TargetType, User: req.User})
+				if err == nil {
+					resp.Followed = res.Followed
+				}
+				return err
+			},
+		)
+	}
+
+	err := mr.Finish(fns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
 }
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type IBlockController interface {
+	DoBlock(ctx context.Context, req *dto.DoBlockReq) (*dto.DoBlockResp, error)
+	CancelBlock(ctx context.Context, req *dto.CancelBlockReq) (*dto.CancelBlockResp, error)
+	GetBlocked(ctx context.Context, req *dto.GetBlockedReq) (*dto.GetBlockedResp, error)
+	GetBlockedUsers(ctx context.Context, req *dto.GetBlockedUsersReq) (*dto.GetBlockedUsersResp, error)
+}
+
+type BlockController struct {
+	blockService service.IBlockService
+}
+
+func NewBlockController() *BlockController {
+	return &BlockController{
+		blockService: service.NewBlockService(),
+	}
+}
+
+func (controller *BlockController) DoBlock(ctx context.Context, req *dto.DoBlockReq) (*dto.DoBlockResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 目标校验
+	targetErr := consts.CheckUserId(req.TargetId)
+	if targetErr != nil {
+		return nil, targetErr
+	}
+
+	resp, err := controller.blockService.DoBlock(ctx, req.TargetId, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *BlockController) CancelBlock(ctx context.Context, req *dto.CancelBlockReq) (*dto.CancelBlockResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.blockService.CancelBlock(ctx, req.TargetId, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *BlockController) GetBlocked(ctx context.Context, req *dto.GetBlockedReq) (*dto.GetBlockedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.blockService.GetBlocked(ctx, req.TargetId, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *BlockController) GetBlockedUsers(ctx context.Context, req *dto.GetBlockedUsersReq) (*dto.GetBlockedUsersResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.blockService.GetBlockedUsers(ctx, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/ratelimit"
+	"meowcloud-action/service"
+)
+
+type IFavoriteController interface {
+	DoFavorite(ctx context.Context, req *dto.DoFavoriteReq) (*dto.DoFavoriteResp, error)
+	CancelFavorite(ctx context.Context, req *dto.CancelFavoriteReq) (*dto.CancelFavoriteResp, error)
+	GetFavorited(ctx context.Context, req *dto.GetFavoritedReq) (*dto.GetFavoritedResp, error)
+	BatchGetFavorited(ctx context.Context, req *dto.BatchGetFavoritedReq) (*dto.BatchGetFavoritedResp, error)
+	GetFavoritedCount(ctx context.Context, req *dto.GetFavoritedCountReq) (*dto.GetFavoritedCountResp, error)
+	BatchGetFavoritedCount(ctx context.Context, req *dto.BatchGetFavoritedCountReq) (*dto.BatchGetFavoritedCountResp, error)
+	CreateFavoriteCollection(ctx context.Context, req *dto.CreateFavoriteCollectionReq) (*dto.CreateFavoriteCollectionResp, error)
+	RenameFavoriteCollection(ctx context.Context, req *dto.RenameFavoriteCollectionReq) (*dto.RenameFavoriteCollectionResp, error)
+	DeleteFavoriteCollection(ctx context.Context, req *dto.DeleteFavoriteCollectionReq) (*dto.DeleteFavoriteCollectionResp, error)
+	ReorderFavoriteCollections(ctx context.Context, req *dto.ReorderFavoriteCollectionsReq) (*dto.ReorderFavoriteCollectionsResp, error)
+	GetFavoriteCollections(ctx context.Context, req *dto.GetFavoriteCollectionsReq) (*dto.GetFavoriteCollectionsResp, error)
+	GetCollectionFavorites(ctx context.Context, req *dto.GetCollectionFavoritesReq) (*dto.GetCollectionFavoritesResp, error)
+}
+
+type FavoriteController struct {
+	favoriteService service.IFavoriteService
+	limiter         *ratelimit.Limiter
+}
+
+func NewFavoriteController() *FavoriteController {
+	return &FavoriteController{
+		favoriteService: service.NewFavoriteService(),
+		limiter:         ratelimit.NewLimiter(),
+	}
+}
+
+func (controller *FavoriteController) DoFavorite(ctx context.Context, req *dto.DoFavoriteReq) (*dto.DoFavoriteResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 限流校验
+	if !controller.limiter.Allow(ctx, event.Favorite, req.TargetType, userMeta.UserId) {
+		return nil, consts.TooManyRequests
+	}
+
+	resp, err := controller.favoriteService.DoFavorite(ctx, req.TargetId, req.TargetType, userMeta.UserId, req.CollectionId)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) CancelFavorite(ctx context.Context, req *dto.CancelFavoriteReq) (*dto.CancelFavoriteResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.favoriteService.CancelFavorite(ctx, req.TargetId, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) GetFavorited(ctx context.Context, req *dto.GetFavoritedReq) (*dto.GetFavoritedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.favoriteService.GetFavorited(ctx, req.TargetId, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) BatchGetFavorited(ctx context.Context, req *dto.BatchGetFavoritedReq) (*dto.BatchGetFavoritedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.favoriteService.BatchGetFavorited(ctx, req.TargetIds, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) GetFavoritedCount(ctx context.Context, req *dto.GetFavoritedCountReq) (*dto.GetFavoritedCountResp, error) {
+
+	resp, err := controller.favoriteService.GetFavoritedCount(ctx, req.TargetId, req.TargetType)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) BatchGetFavoritedCount(ctx context.Context, req *dto.BatchGetFavoritedCountReq) (*dto.BatchGetFavoritedCountResp, error) {
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.favoriteService.BatchGetFavoritedCount(ctx, req.TargetIds, req.TargetType)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) CreateFavoriteCollection(ctx context.Context, req *dto.CreateFavoriteCollectionReq) (*dto.CreateFavoriteCollectionResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 名称校验
+	nameErr := consts.CheckCollectionName(req.Name)
+	if nameErr != nil {
+		return nil, nameErr
+	}
+
+	resp, err := controller.favoriteService.CreateCollection(ctx, userMeta.UserId, req.Name)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) RenameFavoriteCollection(ctx context.Context, req *dto.RenameFavoriteCollectionReq) (*dto.RenameFavoriteCollectionResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 名称校验
+	nameErr := consts.CheckCollectionName(req.Name)
+	if nameErr != nil {
+		return nil, nameErr
+	}
+
+	resp, err := controller.favoriteService.RenameCollection(ctx, req.CollectionId, userMeta.UserId, req.Name)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) DeleteFavoriteCollection(ctx context.Context, req *dto.DeleteFavoriteCollectionReq) (*dto.DeleteFavoriteCollectionResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.favoriteService.DeleteCollection(ctx, req.CollectionId, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) ReorderFavoriteCollections(ctx context.Context, req *dto.ReorderFavoriteCollectionsReq) (*dto.ReorderFavoriteCollectionsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.favoriteService.ReorderCollections(ctx, userMeta.UserId, req.CollectionIds)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) GetFavoriteCollections(ctx context.Context, req *dto.GetFavoriteCollectionsReq) (*dto.GetFavoriteCollectionsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.favoriteService.GetCollections(ctx, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *FavoriteController) GetCollectionFavorites(ctx context.Context, req *dto.GetCollectionFavoritesReq) (*dto.GetCollectionFavoritesResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.favoriteService.GetCollectionFavorites(ctx, req.CollectionId, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
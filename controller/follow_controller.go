@@ -4,6 +4,9 @@ import (
 	"context"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
 	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/ratelimit"
 	"meowcloud-action/service"
 )
 
@@ -11,18 +14,31 @@ type IFollowController interface {
 	DoFollow(ctx context.Context, req *action.DoFollowReq) (*action.DoFollowResp, error)
 	CancelFollow(ctx context.Context, req *action.CancelFollowReq) (*action.CancelFollowResp, error)
 	GetFollowedCount(ctx context.Context, req *action.GetFollowedCountReq) (*action.GetFollowedCountResp, error)
+	BatchGetFollowedCount(ctx context.Context, req *dto.BatchGetFollowedCountReq) (*dto.BatchGetFollowedCountResp, error)
 	GetFollowedUsers(ctx context.Context, req *action.GetFollowedUsersReq) (*action.GetFollowedUsersResp, error)
 	GetUserFollowed(ctx context.Context, req *action.GetUserFollowedReq) (*action.GetUserFollowedResp, error)
 	GetFollowed(ctx context.Context, req *action.GetFollowedReq) (*action.GetFollowedResp, error)
+	BatchGetFollowed(ctx context.Context, req *dto.BatchGetFollowedReq) (*dto.BatchGetFollowedResp, error)
+	GetFollowedUsersWithMutual(ctx context.Context, req *action.GetFollowedUsersReq) (*dto.GetFollowedUsersResp, error)
+	GetUserFollowedWithMutual(ctx context.Context, req *action.GetUserFollowedReq) (*dto.GetUserFollowedResp, error)
+	GetMutualFollowed(ctx context.Context, req *dto.GetMutualFollowedReq) (*dto.GetMutualFollowedResp, error)
+	GetMutualFollows(ctx context.Context, req *dto.GetMutualFollowsReq) (*dto.GetMutualFollowsResp, error)
+	GetFollowingNotFollowedBack(ctx context.Context, req *dto.GetNotFollowedBackReq) (*dto.GetNotFollowedBackResp, error)
+	GetFollowersNotFollowedBack(ctx context.Context, req *dto.GetNotFollowedBackReq) (*dto.GetNotFollowedBackResp, error)
+	GetCommonFollowers(ctx context.Context, req *dto.GetCommonFollowersReq) (*dto.GetCommonFollowersResp, error)
+	GetFollowedUsersWithToken(ctx context.Context, req *action.GetFollowedUsersReq) (*dto.GetFollowedUsersResp, error)
+	GetUserFollowedWithToken(ctx context.Context, req *action.GetUserFollowedReq) (*dto.GetUserFollowedResp, error)
 }
 
 type FollowController struct {
 	followService service.IFollowService
+	limiter       *ratelimit.Limiter
 }
 
 func NewFollowController() *FollowController {
 	return &FollowController{
 		followService: service.NewFollowService(),
+		limiter:       ratelimit.NewLimiter(),
 	}
 }
 
@@ -35,6 +51,11 @@ func (controller *FollowController) DoFollow(ctx context.Context, req *action.Do
 		return nil, userErr
 	}
 
+	// 限流校验
+	if !controller.limiter.Allow(ctx, event.Follow, req.TargetType, userMeta.UserId) {
+		return nil, consts.TooManyRequests
+	}
+
 	resp, err := controller.followService.DoFollow(ctx, req.TargetId, req.TargetType, req.User.UserId)
 
 	return resp, err
@@ -95,3 +116,160 @@ func (controller *FollowController) GetFollowed(ctx context.Context, req *action
 
 	return resp, err
 }
+
+func (controller *FollowController) BatchGetFollowed(ctx context.Context, req *dto.BatchGetFollowedReq) (*dto.BatchGetFollowedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.followService.BatchGetFollowed(ctx, req.TargetIds, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *FollowController) BatchGetFollowedCount(ctx context.Context, req *dto.BatchGetFollowedCountReq) (*dto.BatchGetFollowedCountResp, error) {
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.followService.BatchGetFollowedCount(ctx, req.TargetIds, req.TargetType)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetFollowedUsersWithMutual(ctx context.Context, req *action.GetFollowedUsersReq) (*dto.GetFollowedUsersResp, error) {
+
+	resp, err := controller.followService.GetFollowedUsersWithMutual(ctx, req.TargetId, req.TargetType, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetUserFollowedWithMutual(ctx context.Context, req *action.GetUserFollowedReq) (*dto.GetUserFollowedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followService.GetUserFollowedWithMutual(ctx, req.TargetType, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetMutualFollowed(ctx context.Context, req *dto.GetMutualFollowedReq) (*dto.GetMutualFollowedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 目标校验
+	targetErr := consts.CheckUserId(req.TargetId)
+	if targetErr != nil {
+		return nil, targetErr
+	}
+
+	resp, err := controller.followService.GetMutualFollowed(ctx, req.TargetId, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetMutualFollows(ctx context.Context, req *dto.GetMutualFollowsReq) (*dto.GetMutualFollowsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followService.GetMutualFollows(ctx, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetFollowingNotFollowedBack(ctx context.Context, req *dto.GetNotFollowedBackReq) (*dto.GetNotFollowedBackResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followService.GetFollowingNotFollowedBack(ctx, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetFollowersNotFollowedBack(ctx context.Context, req *dto.GetNotFollowedBackReq) (*dto.GetNotFollowedBackResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followService.GetFollowersNotFollowedBack(ctx, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetCommonFollowers(ctx context.Context, req *dto.GetCommonFollowersReq) (*dto.GetCommonFollowersResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 目标校验
+	targetErr := consts.CheckUserId(req.TargetId)
+	if targetErr != nil {
+		return nil, targetErr
+	}
+
+	resp, err := controller.followService.GetCommonFollowers(ctx, req.TargetId, userMeta.UserId, req.Limit)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetFollowedUsersWithToken(ctx context.Context, req *action.GetFollowedUsersReq) (*dto.GetFollowedUsersResp, error) {
+
+	resp, err := controller.followService.GetFollowedUsersWithToken(ctx, req.TargetId, req.TargetType, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowController) GetUserFollowedWithToken(ctx context.Context, req *action.GetUserFollowedReq) (*dto.GetUserFollowedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followService.GetUserFollowedWithToken(ctx, req.TargetType, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type IFollowRequestController interface {
+	SetFollowPrivacy(ctx context.Context, req *dto.SetFollowPrivacyReq) (*dto.SetFollowPrivacyResp, error)
+	GetFollowPrivacy(ctx context.Context, req *dto.GetFollowPrivacyReq) (*dto.GetFollowPrivacyResp, error)
+	GetIncomingFollowRequests(ctx context.Context, req *dto.GetFollowRequestsReq) (*dto.GetFollowRequestsResp, error)
+	GetOutgoingFollowRequests(ctx context.Context, req *dto.GetFollowRequestsReq) (*dto.GetFollowRequestsResp, error)
+	ApproveFollowRequest(ctx context.Context, req *dto.HandleFollowRequestReq) (*dto.HandleFollowRequestResp, error)
+	RejectFollowRequest(ctx context.Context, req *dto.HandleFollowRequestReq) (*dto.HandleFollowRequestResp, error)
+	WithdrawFollowRequest(ctx context.Context, req *dto.WithdrawFollowRequestReq) (*dto.WithdrawFollowRequestResp, error)
+}
+
+type FollowRequestController struct {
+	followRequestService service.IFollowRequestService
+}
+
+func NewFollowRequestController() *FollowRequestController {
+	return &FollowRequestController{
+		followRequestService: service.NewFollowRequestService(),
+	}
+}
+
+func (controller *FollowRequestController) SetFollowPrivacy(ctx context.Context, req *dto.SetFollowPrivacyReq) (*dto.SetFollowPrivacyResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followRequestService.SetFollowPrivacy(ctx, userMeta.UserId, req.Private)
+
+	return resp, err
+}
+
+func (controller *FollowRequestController) GetFollowPrivacy(ctx context.Context, req *dto.GetFollowPrivacyReq) (*dto.GetFollowPrivacyResp, error) {
+
+	resp, err := controller.followRequestService.GetFollowPrivacy(ctx, req.TargetId)
+
+	return resp, err
+}
+
+func (controller *FollowRequestController) GetIncomingFollowRequests(ctx context.Context, req *dto.GetFollowRequestsReq) (*dto.GetFollowRequestsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followRequestService.GetIncomingRequests(ctx, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowRequestController) GetOutgoingFollowRequests(ctx context.Context, req *dto.GetFollowRequestsReq) (*dto.GetFollowRequestsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followRequestService.GetOutgoingRequests(ctx, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *FollowRequestController) ApproveFollowRequest(ctx context.Context, req *dto.HandleFollowRequestReq) (*dto.HandleFollowRequestResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 只有被关注的一方可以处理请求
+	resp, err := controller.followRequestService.ApproveFollowRequest(ctx, userMeta.UserId, req.UserId)
+
+	return resp, err
+}
+
+func (controller *FollowRequestController) RejectFollowRequest(ctx context.Context, req *dto.HandleFollowRequestReq) (*dto.HandleFollowRequestResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followRequestService.RejectFollowRequest(ctx, userMeta.UserId, req.UserId)
+
+	return resp, err
+}
+
+func (controller *FollowRequestController) WithdrawFollowRequest(ctx context.Context, req *dto.WithdrawFollowRequestReq) (*dto.WithdrawFollowRequestResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.followRequestService.WithdrawFollowRequest(ctx, req.TargetId, userMeta.UserId)
+
+	return resp, err
+}
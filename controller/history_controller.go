@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type IHistoryController interface {
+	GetUserHistory(ctx context.Context, req *dto.GetUserHistoryReq) (*dto.GetUserHistoryResp, error)
+	GetTargetHistory(ctx context.Context, req *dto.GetTargetHistoryReq) (*dto.GetTargetHistoryResp, error)
+}
+
+type HistoryController struct {
+	historyService service.IHistoryService
+}
+
+func NewHistoryController() *HistoryController {
+	return &HistoryController{
+		historyService: service.NewHistoryService(),
+	}
+}
+
+func (controller *HistoryController) GetUserHistory(ctx context.Context, req *dto.GetUserHistoryReq) (*dto.GetUserHistoryResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.historyService.GetUserHistory(ctx, userMeta.UserId, req.Action, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *HistoryController) GetTargetHistory(ctx context.Context, req *dto.GetTargetHistoryReq) (*dto.GetTargetHistoryResp, error) {
+
+	resp, err := controller.historyService.GetTargetHistory(ctx, req.TargetId, req.TargetType, req.Action, req.PaginationOption)
+
+	return resp, err
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
 	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/ratelimit"
 	"meowcloud-action/service"
 )
 
@@ -11,18 +14,24 @@ type ILikeController interface {
 	DoLike(ctx context.Context, req *action.DoLikeReq) (res *action.DoLikeResp, err error)
 	CancelLike(ctx context.Context, req *action.CancelLikeReq) (*action.CancelLikeResp, error)
 	GetLikedCount(ctx context.Context, req *action.GetLikedCountReq) (*action.GetLikedCountResp, error)
+	BatchGetLikedCount(ctx context.Context, req *dto.BatchGetLikedCountReq) (*dto.BatchGetLikedCountResp, error)
 	GetLikedUsers(ctx context.Context, req *action.GetLikedUsersReq) (*action.GetLikedUsersResp, error)
 	GetUserLiked(ctx context.Context, req *action.GetUserLikedReq) (*action.GetUserLikedResp, error)
 	GetLiked(ctx context.Context, req *action.GetLikedReq) (*action.GetLikedResp, error)
+	BatchGetLiked(ctx context.Context, req *dto.BatchGetLikedReq) (*dto.BatchGetLikedResp, error)
+	GetLikedUsersWithToken(ctx context.Context, req *action.GetLikedUsersReq) (*dto.GetLikedUsersResp, error)
+	GetUserLikedWithToken(ctx context.Context, req *action.GetUserLikedReq) (*dto.GetUserLikedResp, error)
 }
 
 type LikeController struct {
 	likeService service.ILikeService
+	limiter     *ratelimit.Limiter
 }
 
 func NewLikeController() *LikeController {
 	return &LikeController{
 		likeService: service.NewLikeService(),
+		limiter:     ratelimit.NewLimiter(),
 	}
 }
 
@@ -35,6 +44,11 @@ func (controller *LikeController) DoLike(ctx context.Context, req *action.DoLike
 		return nil, userErr
 	}
 
+	// 限流校验
+	if !controller.limiter.Allow(ctx, event.Like, req.TargetType, userMeta.UserId) {
+		return nil, consts.TooManyRequests
+	}
+
 	resp, err := controller.likeService.DoLike(ctx, req.TargetId, req.TargetType, req.User.UserId)
 
 	return resp, err
@@ -95,3 +109,57 @@ func (controller *LikeController) GetLiked(ctx context.Context, req *action.GetL
 
 	return resp, err
 }
+
+func (controller *LikeController) BatchGetLiked(ctx context.Context, req *dto.BatchGetLikedReq) (*dto.BatchGetLikedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.likeService.BatchGetLiked(ctx, req.TargetIds, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *LikeController) BatchGetLikedCount(ctx context.Context, req *dto.BatchGetLikedCountReq) (*dto.BatchGetLikedCountResp, error) {
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.likeService.BatchGetLikedCount(ctx, req.TargetIds, req.TargetType)
+
+	return resp, err
+}
+
+func (controller *LikeController) GetLikedUsersWithToken(ctx context.Context, req *action.GetLikedUsersReq) (*dto.GetLikedUsersResp, error) {
+
+	resp, err := controller.likeService.GetLikedUsersWithToken(ctx, req.TargetId, req.TargetType, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *LikeController) GetUserLikedWithToken(ctx context.Context, req *action.GetUserLikedReq) (*dto.GetUserLikedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.likeService.GetUserLikedWithToken(ctx, req.TargetType, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/ratelimit"
+	"meowcloud-action/service"
+)
+
+type IReactionController interface {
+	DoReaction(ctx context.Context, req *dto.DoReactionReq) (*dto.DoReactionResp, error)
+	CancelReaction(ctx context.Context, req *dto.CancelReactionReq) (*dto.CancelReactionResp, error)
+	GetReaction(ctx context.Context, req *dto.GetReactionReq) (*dto.GetReactionResp, error)
+	GetReactionCounts(ctx context.Context, req *dto.GetReactionCountsReq) (*dto.GetReactionCountsResp, error)
+	GetReactedUsers(ctx context.Context, req *dto.GetReactedUsersReq) (*dto.GetReactedUsersResp, error)
+	GetReactionOptions(ctx context.Context, req *dto.GetReactionOptionsReq) (*dto.GetReactionOptionsResp, error)
+}
+
+type ReactionController struct {
+	reactionService service.IReactionService
+	limiter         *ratelimit.Limiter
+}
+
+func NewReactionController() *ReactionController {
+	return &ReactionController{
+		reactionService: service.NewReactionService(),
+		limiter:         ratelimit.NewLimiter(),
+	}
+}
+
+func (controller *ReactionController) DoReaction(ctx context.Context, req *dto.DoReactionReq) (*dto.DoReactionResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 限流校验，与点赞共用规则
+	if !controller.limiter.Allow(ctx, event.Like, req.TargetType, userMeta.UserId) {
+		return nil, consts.TooManyRequests
+	}
+
+	resp, err := controller.reactionService.DoReaction(ctx, req.TargetId, req.TargetType, userMeta.UserId, req.Reaction)
+
+	return resp, err
+}
+
+func (controller *ReactionController) CancelReaction(ctx context.Context, req *dto.CancelReactionReq) (*dto.CancelReactionResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.reactionService.CancelReaction(ctx, req.TargetId, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *ReactionController) GetReaction(ctx context.Context, req *dto.GetReactionReq) (*dto.GetReactionResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.reactionService.GetReaction(ctx, req.TargetId, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *ReactionController) GetReactionCounts(ctx context.Context, req *dto.GetReactionCountsReq) (*dto.GetReactionCountsResp, error) {
+
+	resp, err := controller.reactionService.GetReactionCounts(ctx, req.TargetId, req.TargetType)
+
+	return resp, err
+}
+
+func (controller *ReactionController) GetReactedUsers(ctx context.Context, req *dto.GetReactedUsersReq) (*dto.GetReactedUsersResp, error) {
+
+	resp, err := controller.reactionService.GetReactedUsers(ctx, req.TargetId, req.TargetType, req.Reaction, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *ReactionController) GetReactionOptions(ctx context.Context, req *dto.GetReactionOptionsReq) (*dto.GetReactionOptionsResp, error) {
+
+	resp, err := controller.reactionService.GetReactionOptions(ctx)
+
+	return resp, err
+}
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type IRecommendController interface {
+	GetFollowRecommendations(ctx context.Context, req *dto.GetFollowRecommendationsReq) (*dto.GetFollowRecommendationsResp, error)
+}
+
+type RecommendController struct {
+	recommendService service.IRecommendService
+}
+
+func NewRecommendController() *RecommendController {
+	return &RecommendController{
+		recommendService: service.NewRecommendService(),
+	}
+}
+
+func (controller *RecommendController) GetFollowRecommendations(ctx context.Context, req *dto.GetFollowRecommendationsReq) (*dto.GetFollowRecommendationsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.recommendService.GetFollowRecommendations(ctx, req.TargetType, userMeta.UserId, req.Limit)
+
+	return resp, err
+}
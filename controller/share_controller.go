@@ -4,24 +4,35 @@ import (
 	"context"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
 	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/ratelimit"
 	"meowcloud-action/service"
 )
 
 type IShareController interface {
 	DoShare(ctx context.Context, req *action.DoShareReq) (*action.DoShareResp, error)
 	GetSharedCount(ctx context.Context, req *action.GetSharedCountReq) (*action.GetSharedCountResp, error)
+	BatchGetSharedCount(ctx context.Context, req *dto.BatchGetSharedCountReq) (*dto.BatchGetSharedCountResp, error)
 	GetSharedUsers(ctx context.Context, req *action.GetSharedUsersReq) (*action.GetSharedUsersResp, error)
 	GetUserShared(ctx context.Context, req *action.GetUserSharedReq) (*action.GetUserSharedResp, error)
 	GetShared(ctx context.Context, req *action.GetSharedReq) (*action.GetSharedResp, error)
+	BatchGetShared(ctx context.Context, req *dto.BatchGetSharedReq) (*dto.BatchGetSharedResp, error)
+	DoShareWithChannel(ctx context.Context, req *dto.DoShareReq) (*dto.DoShareResp, error)
+	GetSharedCountBreakdown(ctx context.Context, req *dto.GetSharedCountBreakdownReq) (*dto.GetSharedCountBreakdownResp, error)
+	GetSharedUsersWithToken(ctx context.Context, req *action.GetSharedUsersReq) (*dto.GetSharedUsersResp, error)
+	GetUserSharedWithToken(ctx context.Context, req *action.GetUserSharedReq) (*dto.GetUserSharedResp, error)
 }
 
 type ShareController struct {
 	shareService service.IShareService
+	limiter      *ratelimit.Limiter
 }
 
 func NewShareController() *ShareController {
 	return &ShareController{
 		shareService: service.NewShareService(),
+		limiter:      ratelimit.NewLimiter(),
 	}
 }
 
@@ -34,9 +45,18 @@ func (controller *ShareController) DoShare(ctx context.Context, req *action.DoSh
 		return nil, userErr
 	}
 
-	resp, err := controller.shareService.DoShare(ctx, req.TargetId, req.TargetType, req.User.UserId)
+	// 限流校验
+	if !controller.limiter.Allow(ctx, event.Share, req.TargetType, userMeta.UserId) {
+		return nil, consts.TooManyRequests
+	}
 
-	return resp, err
+	_, err := controller.shareService.DoShare(ctx, req.TargetId, req.TargetType, req.User.UserId, consts.ShareChannelUnknown, nil, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &action.DoShareResp{}, nil
 }
 
 func (controller *ShareController) GetSharedCount(ctx context.Context, req *action.GetSharedCountReq) (*action.GetSharedCountResp, error) {
@@ -80,3 +100,100 @@ func (controller *ShareController) GetShared(ctx context.Context, req *action.Ge
 
 	return resp, err
 }
+
+func (controller *ShareController) BatchGetShared(ctx context.Context, req *dto.BatchGetSharedReq) (*dto.BatchGetSharedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.shareService.BatchGetShared(ctx, req.TargetIds, req.TargetType, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *ShareController) BatchGetSharedCount(ctx context.Context, req *dto.BatchGetSharedCountReq) (*dto.BatchGetSharedCountResp, error) {
+
+	// 批量数量校验
+	err := consts.CheckTargetIds(req.TargetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := controller.shareService.BatchGetSharedCount(ctx, req.TargetIds, req.TargetType)
+
+	return resp, err
+}
+
+func (controller *ShareController) DoShareWithChannel(ctx context.Context, req *dto.DoShareReq) (*dto.DoShareResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	channel := req.Channel
+	if channel == "" {
+		channel = consts.ShareChannelUnknown
+	}
+
+	// 渠道校验
+	channelErr := consts.CheckShareChannel(channel, req.Metadata)
+	if channelErr != nil {
+		return nil, channelErr
+	}
+
+	// 幂等键校验
+	keyErr := consts.CheckIdempotencyKey(req.IdempotencyKey)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	// 限流校验
+	if !controller.limiter.Allow(ctx, event.Share, req.TargetType, userMeta.UserId) {
+		return nil, consts.TooManyRequests
+	}
+
+	resp, err := controller.shareService.DoShare(ctx, req.TargetId, req.TargetType, userMeta.UserId, channel, req.Metadata, req.IdempotencyKey)
+
+	return resp, err
+}
+
+func (controller *ShareController) GetSharedCountBreakdown(ctx context.Context, req *dto.GetSharedCountBreakdownReq) (*dto.GetSharedCountBreakdownResp, error) {
+
+	resp, err := controller.shareService.GetSharedCountBreakdown(ctx, req.TargetId, req.TargetType)
+
+	return resp, err
+}
+
+func (controller *ShareController) GetSharedUsersWithToken(ctx context.Context, req *action.GetSharedUsersReq) (*dto.GetSharedUsersResp, error) {
+
+	resp, err := controller.shareService.GetSharedUsersWithToken(ctx, req.TargetId, req.TargetType, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *ShareController) GetUserSharedWithToken(ctx context.Context, req *action.GetUserSharedReq) (*dto.GetUserSharedResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.shareService.GetUserSharedWithToken(ctx, req.TargetType, userMeta.UserId, req.PaginationOption)
+
+	return resp, err
+}
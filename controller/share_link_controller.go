@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type IShareLinkController interface {
+	ResolveShareCode(ctx context.Context, req *dto.ResolveShareCodeReq) (*dto.ResolveShareCodeResp, error)
+	CreditShareConversion(ctx context.Context, req *dto.CreditShareConversionReq) (*dto.CreditShareConversionResp, error)
+	RecordShareSignUp(ctx context.Context, req *dto.RecordShareSignUpReq) (*dto.RecordShareSignUpResp, error)
+	GetShareStats(ctx context.Context, req *dto.GetShareStatsReq) (*dto.GetShareStatsResp, error)
+	GetUserShareStats(ctx context.Context, req *dto.GetUserShareStatsReq) (*dto.GetUserShareStatsResp, error)
+}
+
+type ShareLinkController struct {
+	shareLinkService service.IShareLinkService
+}
+
+func NewShareLinkController() *ShareLinkController {
+	return &ShareLinkController{
+		shareLinkService: service.NewShareLinkService(),
+	}
+}
+
+func (controller *ShareLinkController) ResolveShareCode(ctx context.Context, req *dto.ResolveShareCodeReq) (*dto.ResolveShareCodeResp, error) {
+
+	// 允许未登录访问
+	var userId string
+	if req.User != nil {
+		userId = req.User.UserId
+	}
+
+	resp, err := controller.shareLinkService.ResolveShareCode(ctx, req.Code, userId)
+
+	return resp, err
+}
+
+func (controller *ShareLinkController) CreditShareConversion(ctx context.Context, req *dto.CreditShareConversionReq) (*dto.CreditShareConversionResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.shareLinkService.CreditShareConversion(ctx, req.Code, req.Kind, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *ShareLinkController) RecordShareSignUp(ctx context.Context, req *dto.RecordShareSignUpReq) (*dto.RecordShareSignUpResp, error) {
+
+	// 用户信息校验
+	userErr := consts.CheckUserId(req.UserId)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.shareLinkService.RecordShareSignUp(ctx, req.Code, req.UserId)
+
+	return resp, err
+}
+
+func (controller *ShareLinkController) GetShareStats(ctx context.Context, req *dto.GetShareStatsReq) (*dto.GetShareStatsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.shareLinkService.GetShareStats(ctx, req.Code, userMeta.UserId)
+
+	return resp, err
+}
+
+func (controller *ShareLinkController) GetUserShareStats(ctx context.Context, req *dto.GetUserShareStatsReq) (*dto.GetUserShareStatsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.shareLinkService.GetUserShareStats(ctx, userMeta.UserId)
+
+	return resp, err
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type ITargetController interface {
+	DeleteTargetActions(ctx context.Context, req *dto.DeleteTargetActionsReq) (*dto.DeleteTargetActionsResp, error)
+	ReconcileCounts(ctx context.Context, req *dto.ReconcileCountsReq) (*dto.ReconcileCountsResp, error)
+}
+
+type TargetController struct {
+	targetService service.ITargetService
+}
+
+func NewTargetController() *TargetController {
+	return &TargetController{
+		targetService: service.NewTargetService(),
+	}
+}
+
+func (controller *TargetController) DeleteTargetActions(ctx context.Context, req *dto.DeleteTargetActionsReq) (*dto.DeleteTargetActionsResp, error) {
+
+	// 目标校验，避免空targetId匹配到字段缺失的记录
+	targetErr := consts.CheckTargetId(req.TargetId)
+	if targetErr != nil {
+		return nil, targetErr
+	}
+
+	resp, err := controller.targetService.DeleteTargetActions(ctx, req.TargetId, req.TargetType, req.DryRun)
+
+	return resp, err
+}
+
+func (controller *TargetController) ReconcileCounts(ctx context.Context, req *dto.ReconcileCountsReq) (*dto.ReconcileCountsResp, error) {
+
+	// 目标校验
+	targetErr := consts.CheckTargetId(req.TargetId)
+	if targetErr != nil {
+		return nil, targetErr
+	}
+
+	resp, err := controller.targetService.ReconcileCounts(ctx, req.TargetId, req.TargetType)
+
+	return resp, err
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type IUserDataController interface {
+	EraseUserActions(ctx context.Context, req *dto.EraseUserActionsReq) (*dto.EraseUserActionsResp, error)
+	ExportUserActions(ctx context.Context, req *dto.ExportUserActionsReq) (*dto.ExportUserActionsResp, error)
+}
+
+type UserDataController struct {
+	userDataService service.IUserDataService
+}
+
+func NewUserDataController() *UserDataController {
+	return &UserDataController{
+		userDataService: service.NewUserDataService(),
+	}
+}
+
+func (controller *UserDataController) EraseUserActions(ctx context.Context, req *dto.EraseUserActionsReq) (*dto.EraseUserActionsResp, error) {
+
+	// 用户信息校验
+	userErr := consts.CheckUserId(req.UserId)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	resp, err := controller.userDataService.EraseUserActions(ctx, req.UserId)
+
+	return resp, err
+}
+
+func (controller *UserDataController) ExportUserActions(ctx context.Context, req *dto.ExportUserActionsReq) (*dto.ExportUserActionsResp, error) {
+	userMeta := req.User
+
+	// 用户信息校验
+	userErr := consts.CheckUserMeta(userMeta)
+	if userErr != nil {
+		return nil, userErr
+	}
+
+	format := req.Format
+	if format == "" {
+		format = consts.ExportFormatJSONL
+	}
+
+	// 每次只导出一段，内存占用与用户的数据量无关
+	var buf bytes.Buffer
+	token, err := controller.userDataService.ExportUserActions(ctx, userMeta.UserId, format, req.Token, consts.ExportChunkSize, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ExportUserActionsResp{
+		Format: format,
+		Data:   buf.Bytes(),
+		Token:  token,
+	}, nil
+}
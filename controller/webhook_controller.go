@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/service"
+)
+
+type IWebhookController interface {
+	CreateWebhook(ctx context.Context, req *dto.CreateWebhookReq) (*dto.CreateWebhookResp, error)
+	DeleteWebhook(ctx context.Context, req *dto.DeleteWebhookReq) (*dto.DeleteWebhookResp, error)
+	ListWebhooks(ctx context.Context, req *dto.ListWebhooksReq) (*dto.ListWebhooksResp, error)
+	ListDeadLetters(ctx context.Context, req *dto.ListDeadLettersReq) (*dto.ListDeadLettersResp, error)
+	ReplayDeadLetter(ctx context.Context, req *dto.ReplayDeadLetterReq) (*dto.ReplayDeadLetterResp, error)
+}
+
+type WebhookController struct {
+	webhookService service.IWebhookService
+}
+
+func NewWebhookController() *WebhookController {
+	return &WebhookController{
+		webhookService: service.NewWebhookService(),
+	}
+}
+
+func (controller *WebhookController) CreateWebhook(ctx context.Context, req *dto.CreateWebhookReq) (*dto.CreateWebhookResp, error) {
+
+	resp, err := controller.webhookService.CreateWebhook(ctx, req.URL, req.Topics, req.TargetTypes)
+
+	return resp, err
+}
+
+func (controller *WebhookController) DeleteWebhook(ctx context.Context, req *dto.DeleteWebhookReq) (*dto.DeleteWebhookResp, error) {
+
+	resp, err := controller.webhookService.DeleteWebhook(ctx, req.Id)
+
+	return resp, err
+}
+
+func (controller *WebhookController) ListWebhooks(ctx context.Context, req *dto.ListWebhooksReq) (*dto.ListWebhooksResp, error) {
+
+	resp, err := controller.webhookService.ListWebhooks(ctx)
+
+	return resp, err
+}
+
+func (controller *WebhookController) ListDeadLetters(ctx context.Context, req *dto.ListDeadLettersReq) (*dto.ListDeadLettersResp, error) {
+
+	resp, err := controller.webhookService.ListDeadLetters(ctx, req.PaginationOption)
+
+	return resp, err
+}
+
+func (controller *WebhookController) ReplayDeadLetter(ctx context.Context, req *dto.ReplayDeadLetterReq) (*dto.ReplayDeadLetterResp, error) {
+
+	resp, err := controller.webhookService.ReplayDeadLetter(ctx, req.Id)
+
+	return resp, err
+}
@@ -0,0 +1,65 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"meowcloud-action/common/config"
+	"time"
+)
+
+const prefixIdempotencyKey = "idempotency"
+
+// 已占用幂等键但还没有保存结果
+const pending = "-"
+
+// Store 在redis中记录窗口期内出现过的幂等键及其结果，同一用户在窗口内重复提交同一个键时直接返回第一次的结果
+type Store struct {
+	rds    *redis.Redis
+	window time.Duration
+}
+
+func NewStore(window time.Duration) *Store {
+	return &Store{
+		rds:    redis.MustNewRedis(config.Get().Cache[0].RedisConf),
+		window: window,
+	}
+}
+
+func (s *Store) key(scope string, userId string, key string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", prefixIdempotencyKey, scope, userId, key)
+}
+
+func (s *Store) seconds() int {
+	return int(s.window / time.Second)
+}
+
+// Acquire 占用幂等键，acquired为false表示窗口内已出现过该键，此时result为第一次请求保存的结果，仍在处理中时为空
+func (s *Store) Acquire(ctx context.Context, scope string, userId string, key string) (result string, acquired bool, err error) {
+	k := s.key(scope, userId, key)
+
+	acquired, err = s.rds.SetnxExCtx(ctx, k, pending, s.seconds())
+	if err != nil || acquired {
+		return "", acquired, err
+	}
+
+	result, err = s.rds.GetCtx(ctx, k)
+	if err != nil {
+		return "", false, err
+	}
+	if result == pending {
+		result = ""
+	}
+	return result, false, nil
+}
+
+// Save 保存请求的结果，窗口从保存时重新计算
+func (s *Store) Save(ctx context.Context, scope string, userId string, key string, result string) error {
+	return s.rds.SetexCtx(ctx, s.key(scope, userId, key), result, s.seconds())
+}
+
+// Release 请求失败时释放幂等键，允许客户端用同一个键重试
+func (s *Store) Release(ctx context.Context, scope string, userId string, key string) error {
+	_, err := s.rds.DelCtx(ctx, s.key(scope, userId, key))
+	return err
+}
@@ -0,0 +1,17 @@
+package block
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Block UserId拉黑了TargetId，只存在于用户之间
+type Block struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TargetId string             `bson:"target_id,omitempty" json:"target_id"`
+	UserId   string             `bson:"user_id,omitempty" json:"user_id"`
+	IsCancel bool               `bson:"is_cancel" json:"is_cancel"`
+	CreateAt time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
+	UpdateAt time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
+	DeleteAt time.Time          `bson:"delete_at,omitempty" json:"delete_at,omitempty"`
+}
@@ -0,0 +1,240 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/pagination"
+	"meowcloud-action/infra/mapper/upsert"
+	"time"
+)
+
+const CollectionName = "block"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// (user_id, target_id)唯一，同时用于IsBlocked、BatchIsBlocked
+	{Name: "user_target_unique", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_id", Value: 1}}, Unique: true},
+	// GetBlockedUsers、CountBlocksByUserId
+	{Name: "user_create_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetEitherBlockedIds中被别人拉黑的部分、DeleteByUserId
+	{Name: "target_user", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "user_id", Value: 1}}},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	InsertOne(ctx context.Context, targetId string, userId string) (bool, error)
+	CancelBlock(ctx context.Context, targetId string, userId string) (bool, error)
+	IsBlocked(ctx context.Context, targetId string, userId string) (bool, error)
+	IsEitherBlocked(ctx context.Context, userA string, userB string) (bool, error)
+	BatchIsBlocked(ctx context.Context, targetIds []string, userId string) (map[string]bool, error)
+	GetEitherBlockedIds(ctx context.Context, userId string) ([]string, error)
+	GetBlockedUsers(ctx context.Context, userId string, options *basic.PaginationOptions) ([]*Block, int64, string, error)
+	CountBlocksByUserId(ctx context.Context, userId string) (int64, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func cursorOf(block *Block) pagination.Cursor {
+	return pagination.Cursor{ID: block.ID, CreateAt: block.CreateAt}
+}
+
+// InsertOne 原子地upsert一条block记录，返回值表示状态是否发生变化，已处于生效状态时返回false
+func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, userId string) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "user_id": userId}
+
+	now := time.Now()
+	update := bson.M{
+		"$set":         bson.M{"is_cancel": false, "update_at": now},
+		"$unset":       bson.M{"delete_at": ""},
+		"$setOnInsert": bson.M{"create_at": now},
+	}
+	var old Block
+
+	err := upsert.FindOneAndUpsert(ctx, m.conn, &old, filter, update)
+
+	switch {
+	// 不存在则新建
+	case errors.Is(err, monc.ErrNotFound):
+		return true, nil
+	// 已经存在则只有原先被取消时才算生效
+	case err == nil:
+		return old.IsCancel, nil
+	default:
+		return false, err
+	}
+}
+
+// CancelBlock 原子地取消一条生效中的block记录，返回值表示是否确实取消了记录
+func (m *MongoMapper) CancelBlock(ctx context.Context, targetId string, userId string) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "user_id": userId, "is_cancel": false}
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"is_cancel": true, "update_at": now, "delete_at": now}}
+
+	var old Block
+
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &old, filter, update)
+
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return false, nil
+	case err == nil:
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// IsBlocked userId是否拉黑了targetId
+func (m *MongoMapper) IsBlocked(ctx context.Context, targetId string, userId string) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "user_id": userId, "is_cancel": false}
+
+	count, err := m.conn.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// IsEitherBlocked 两个用户中是否有一方拉黑了另一方
+func (m *MongoMapper) IsEitherBlocked(ctx context.Context, userA string, userB string) (bool, error) {
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"target_id": userA, "user_id": userB},
+		bson.M{"target_id": userB, "user_id": userA},
+	}, "is_cancel": false}
+
+	count, err := m.conn.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// BatchIsBlocked 用一次$in查询返回userId是否拉黑了每个targetId
+func (m *MongoMapper) BatchIsBlocked(ctx context.Context, targetIds []string, userId string) (map[string]bool, error) {
+
+	result := make(map[string]bool, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = false
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	filter := bson.M{"target_id": bson.M{"$in": targetIds}, "user_id": userId, "is_cancel": false}
+
+	var blocks []*Block
+
+	err := m.conn.Find(ctx, &blocks, filter, options.Find().SetProjection(bson.M{"target_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range blocks {
+		result[val.TargetId] = true
+	}
+
+	return result, nil
+}
+
+// GetEitherBlockedIds 返回被userId拉黑或拉黑了userId的全部用户
+func (m *MongoMapper) GetEitherBlockedIds(ctx context.Context, userId string) ([]string, error) {
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"user_id": userId},
+		bson.M{"target_id": userId},
+	}, "is_cancel": false}
+
+	var blocks []*Block
+
+	err := m.conn.Find(ctx, &blocks, filter, options.Find().SetProjection(bson.M{"target_id": 1, "user_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make([]string, 0, len(blocks))
+	for _, val := range blocks {
+		if val.UserId == userId {
+			userIds = append(userIds, val.TargetId)
+		} else {
+			userIds = append(userIds, val.UserId)
+		}
+	}
+
+	return userIds, nil
+}
+
+func (m *MongoMapper) GetBlockedUsers(ctx context.Context, userId string, opts *basic.PaginationOptions) ([]*Block, int64, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	var blocks []*Block
+
+	filter := bson.M{"user_id": userId, "is_cancel": false}
+
+	err = m.conn.Find(ctx, &blocks, filter, p.MakeFindOptions(filter))
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	blocks, lastToken, err := pagination.Paginate(p, blocks, cursorOf)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	total, err := m.CountBlocksByUserId(ctx, userId)
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return blocks, total, lastToken, nil
+}
+
+func (m *MongoMapper) CountBlocksByUserId(ctx context.Context, userId string) (int64, error) {
+	filter := bson.M{"user_id": userId, "is_cancel": false}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// DeleteByUserId 物理删除用户拉黑别人和被别人拉黑的记录，两个方向都包含该用户的id
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"user_id": userId},
+		bson.M{"target_id": userId},
+	}}
+	return m.conn.DeleteMany(ctx, filter)
+}
@@ -0,0 +1,16 @@
+package collection
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Collection 用户给收藏建立的分组，名称在同一用户下唯一
+type Collection struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserId   string             `bson:"user_id,omitempty" json:"user_id"`
+	Name     string             `bson:"name" json:"name"`
+	Position int64              `bson:"position" json:"position"` // 越小越靠前
+	CreateAt time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
+	UpdateAt time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
+}
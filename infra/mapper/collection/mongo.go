@@ -0,0 +1,240 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"time"
+)
+
+const CollectionName = "favorite_collection"
+
+// LockCollectionName 每个用户一条锁文档，_id为用户id，新建收藏夹时递增version
+const LockCollectionName = "favorite_collection_lock"
+
+var ErrNameExists = errors.New("收藏夹名称已存在")
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// (user_id, name)唯一
+	{Name: "user_name_unique", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}}, Unique: true},
+	// GetByUserId、CountByUserId，以及InsertOne查询最大位置
+	{Name: "user_position", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "position", Value: 1}, {Key: "create_at", Value: 1}}},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	InsertOne(ctx context.Context, userId string, name string) (*Collection, error)
+	FindOne(ctx context.Context, id string, userId string) (*Collection, error)
+	GetByUserId(ctx context.Context, userId string) ([]*Collection, error)
+	CountByUserId(ctx context.Context, userId string) (int64, error)
+	Rename(ctx context.Context, id string, userId string, name string) (bool, error)
+	Touch(ctx context.Context, id string, userId string) (bool, error)
+	Reorder(ctx context.Context, userId string, ids []string) error
+	DeleteOne(ctx context.Context, id string, userId string) (bool, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+}
+
+type MongoMapper struct {
+	conn     *monc.Model
+	lockConn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn:     conn,
+		lockConn: monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, LockCollectionName, aConfig.Cache),
+	}
+}
+
+// Transaction 在事务中执行fn，fn中使用传入的ctx访问任意集合都会加入该事务
+func (m *MongoMapper) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	sess, err := m.conn.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// InsertOne 新建的收藏夹排在最后，名称重复时返回ErrNameExists。
+// 需要在事务中调用：先写用户的锁文档，同一用户并发新建收藏夹的事务会产生写冲突而串行执行，
+// 调用方可以在同一事务中检查数量上限。锁文档被并发创建时返回唯一索引冲突，由调用方重试整个事务
+func (m *MongoMapper) InsertOne(ctx context.Context, userId string, name string) (*Collection, error) {
+
+	update := bson.M{"$inc": bson.M{"version": int64(1)}, "$set": bson.M{"update_at": time.Now()}}
+	if _, err := m.lockConn.UpdateOneNoCache(ctx, bson.M{"_id": userId}, update, options.Update().SetUpsert(true)); err != nil {
+		return nil, err
+	}
+
+	// 排在当前最后一个之后，而不是使用数量，删除过收藏夹后位置也不会重复
+	var last Collection
+	var position int64
+	opts := options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}, {Key: "create_at", Value: -1}})
+	err := m.conn.FindOneNoCache(ctx, &last, bson.M{"user_id": userId}, opts)
+	switch {
+	case err == nil:
+		position = last.Position + 1
+	case !errors.Is(err, monc.ErrNotFound):
+		return nil, err
+	}
+
+	newCollection := &Collection{
+		ID:       primitive.NewObjectID(),
+		UserId:   userId,
+		Name:     name,
+		Position: position,
+		CreateAt: time.Now(),
+		UpdateAt: time.Now(),
+	}
+
+	_, err = m.conn.InsertOneNoCache(ctx, newCollection)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrNameExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newCollection, nil
+}
+
+// FindOne 只查询属于userId的收藏夹，不存在时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, id string, userId string) (*Collection, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var collection Collection
+
+	err = m.conn.FindOneNoCache(ctx, &collection, bson.M{"_id": oid, "user_id": userId})
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &collection, nil
+	default:
+		return nil, err
+	}
+}
+
+// GetByUserId 按用户设置的顺序返回全部收藏夹，数量有上限所以不分页
+func (m *MongoMapper) GetByUserId(ctx context.Context, userId string) ([]*Collection, error) {
+
+	var collections []*Collection
+
+	opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "create_at", Value: 1}})
+
+	err := m.conn.Find(ctx, &collections, bson.M{"user_id": userId}, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+func (m *MongoMapper) CountByUserId(ctx context.Context, userId string) (int64, error) {
+	return m.conn.CountDocuments(ctx, bson.M{"user_id": userId})
+}
+
+// Rename 返回值表示收藏夹是否存在，名称重复时返回ErrNameExists
+func (m *MongoMapper) Rename(ctx context.Context, id string, userId string, name string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	update := bson.M{"$set": bson.M{"name": name, "update_at": time.Now()}}
+
+	res, err := m.conn.UpdateOneNoCache(ctx, bson.M{"_id": oid, "user_id": userId}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, ErrNameExists
+	}
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// Touch 更新收藏夹的update_at，返回收藏夹是否存在。
+// 在收藏的事务中调用，与删除收藏夹的事务写同一文档，使两者产生写冲突，不会把收藏放进正在删除的收藏夹
+func (m *MongoMapper) Touch(ctx context.Context, id string, userId string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	update := bson.M{"$set": bson.M{"update_at": time.Now()}}
+
+	res, err := m.conn.UpdateOneNoCache(ctx, bson.M{"_id": oid, "user_id": userId}, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// Reorder 按ids的顺序重新设置位置，ids需要由调用方保证是用户的全部收藏夹
+func (m *MongoMapper) Reorder(ctx context.Context, userId string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, 0, len(ids))
+	for i, id := range ids {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			continue
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": oid, "user_id": userId}).
+			SetUpdate(bson.M{"$set": bson.M{"position": int64(i), "update_at": now}}))
+	}
+
+	_, err := m.conn.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// DeleteOne 返回值表示是否确实删除了收藏夹
+func (m *MongoMapper) DeleteOne(ctx context.Context, id string, userId string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	n, err := m.conn.DeleteOneNoCache(ctx, bson.M{"_id": oid, "user_id": userId})
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeleteByUserId 物理删除用户的全部收藏夹和锁文档，返回删除的收藏夹数量
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	n, err := m.conn.DeleteMany(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return 0, err
+	}
+	if _, err = m.lockConn.DeleteOneNoCache(ctx, bson.M{"_id": userId}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,27 @@
+package conversion
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Kind 通过分享链接产生的行为
+type Kind string
+
+const (
+	// Visit 访问只计入share.Stats，不再逐条记录，早期版本写入的访问记录仍然存在
+	Visit  Kind = "visit"
+	SignUp Kind = "sign_up"
+	Like   Kind = "like"
+)
+
+// Conversion 一次通过分享链接的转化，SharerId为分享者，UserId为转化的用户
+type Conversion struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ShareId  string             `bson:"share_id" json:"share_id"`
+	SharerId string             `bson:"sharer_id" json:"sharer_id"`
+	Kind     Kind               `bson:"kind" json:"kind"`
+	UserId   string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	DedupKey string             `bson:"dedup_key,omitempty" json:"-"` // 转化只能计入一次
+	CreateAt time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
+}
@@ -0,0 +1,103 @@
+package conversion
+
+import (
+	"context"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"time"
+)
+
+const CollectionName = "share_conversion"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// 保证同一转化只计入一次
+	{Name: "dedup_key_unique", Keys: bson.D{{Key: "dedup_key", Value: 1}}, Unique: true, Sparse: true},
+	// DeleteByUserId
+	{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}}},
+	{Name: "sharer_id", Keys: bson.D{{Key: "sharer_id", Value: 1}}},
+	// DeleteByShareIds
+	{Name: "share_id", Keys: bson.D{{Key: "share_id", Value: 1}}},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	InsertOne(ctx context.Context, conversion *Conversion) (bool, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+	CountByShareIds(ctx context.Context, shareIds []string) (int64, error)
+	DeleteByShareIds(ctx context.Context, shareIds []string) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+// Transaction 在事务中执行fn，fn中使用传入的ctx访问任意集合都会加入该事务
+func (m *MongoMapper) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	sess, err := m.conn.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// InsertOne 返回值表示是否写入，DedupKey重复时返回false。在事务中重复时事务已经被中止，调用方需要返回错误结束事务
+func (m *MongoMapper) InsertOne(ctx context.Context, conversion *Conversion) (bool, error) {
+	conversion.ID = primitive.NewObjectID()
+	conversion.CreateAt = time.Now()
+
+	_, err := m.conn.InsertOneNoCache(ctx, conversion)
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		return false, nil
+	case err == nil:
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// DeleteByUserId 物理删除用户作为访问者或分享者的全部记录
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	filter := bson.M{"$or": bson.A{bson.M{"user_id": userId}, bson.M{"sharer_id": userId}}}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
+
+// CountByShareIds 统计通过这些分享产生的访问和转化
+func (m *MongoMapper) CountByShareIds(ctx context.Context, shareIds []string) (int64, error) {
+	if len(shareIds) == 0 {
+		return 0, nil
+	}
+	return m.conn.CountDocuments(ctx, bson.M{"share_id": bson.M{"$in": shareIds}})
+}
+
+// DeleteByShareIds 物理删除通过这些分享产生的访问和转化，返回删除的数量
+func (m *MongoMapper) DeleteByShareIds(ctx context.Context, shareIds []string) (int64, error) {
+	if len(shareIds) == 0 {
+		return 0, nil
+	}
+	return m.conn.DeleteMany(ctx, bson.M{"share_id": bson.M{"$in": shareIds}})
+}
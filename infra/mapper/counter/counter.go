@@ -0,0 +1,27 @@
+package counter
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Kind 计数的种类，对应Counter.Counts中的key
+type Kind string
+
+const (
+	Like     Kind = "like"
+	Follow   Kind = "follow"
+	Share    Kind = "share"
+	Favorite Kind = "favorite"
+)
+
+// Counter 每个(target_id, target_type)一条，Inited中缺少某个Kind表示该计数尚未初始化，此时Counts中的值没有意义
+type Counter struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TargetId   string             `bson:"target_id,omitempty" json:"target_id"`
+	TargetType action.TargetType  `bson:"target_type" json:"target_type"`
+	Counts     map[Kind]int64     `bson:"counts" json:"counts"`
+	Inited     map[Kind]bool      `bson:"inited,omitempty" json:"inited,omitempty"`
+	UpdateAt   time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
+}
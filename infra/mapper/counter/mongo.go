@@ -0,0 +1,237 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"time"
+)
+
+const CollectionName = "counter"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	{Name: "target_unique", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}}, Unique: true},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	Incr(ctx context.Context, targetId string, targetType action.TargetType, kind Kind, delta int64) error
+	Get(ctx context.Context, targetId string, targetType action.TargetType, kind Kind) (int64, bool, error)
+	BatchGet(ctx context.Context, targetIds []string, targetType action.TargetType, kind Kind) (map[string]int64, error)
+	Init(ctx context.Context, targetId string, targetType action.TargetType, kind Kind, count CountFunc) (int64, error)
+	BatchInit(ctx context.Context, targetIds []string, targetType action.TargetType, kind Kind, count BatchCountFunc) (map[string]int64, error)
+	Reconcile(ctx context.Context, targetId string, targetType action.TargetType, kind Kind, count CountFunc) (int64, error)
+	Delete(ctx context.Context, targetId string, targetType action.TargetType) error
+}
+
+// CountFunc 在Init的快照事务中从明细统计计数
+type CountFunc func(ctx context.Context) (int64, error)
+
+// BatchCountFunc 在BatchInit的快照事务中从明细统计多个目标的计数，缺少的目标视为0
+type BatchCountFunc func(ctx context.Context) (map[string]int64, error)
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func countKey(kind Kind) string {
+	return "counts." + string(kind)
+}
+
+func initedKey(kind Kind) string {
+	return "inited." + string(kind)
+}
+
+// initUpdate 把计数设置为count并标记为已初始化，force为false时已初始化的计数保持不变
+func initUpdate(kind Kind, count int64, force bool) mongo.Pipeline {
+	var value any = count
+	if !force {
+		value = bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$" + initedKey(kind), true}}, "$" + countKey(kind), count}}
+	}
+	return mongo.Pipeline{{{Key: "$set", Value: bson.M{countKey(kind): value, initedKey(kind): true, "update_at": time.Now()}}}}
+}
+
+// snapshot 在快照读的事务中执行fn。统计明细之后、提交之前，并发事务对同一计数文档的Incr会与本事务写冲突，
+// WithTransaction会重新统计后重试，因此初始化不会覆盖掉增量
+func (m *MongoMapper) snapshot(ctx context.Context, fn func(ctx context.Context) error) error {
+	sess, err := m.conn.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	opts := options.Transaction().SetReadConcern(readconcern.Snapshot())
+	_, err = sess.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	}, opts)
+	return err
+}
+
+// Incr 需要和明细变更在同一事务中调用，总是写入计数文档，未初始化的计数会在初始化时被覆盖
+func (m *MongoMapper) Incr(ctx context.Context, targetId string, targetType action.TargetType, kind Kind, delta int64) error {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+	update := bson.M{
+		"$inc": bson.M{countKey(kind): delta},
+		"$set": bson.M{"update_at": time.Now()},
+	}
+
+	_, err := m.conn.UpdateOneNoCache(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// Get 返回计数以及该计数是否已经初始化
+func (m *MongoMapper) Get(ctx context.Context, targetId string, targetType action.TargetType, kind Kind) (int64, bool, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	var counter Counter
+
+	err := m.conn.FindOneNoCache(ctx, &counter, filter)
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return 0, false, nil
+	case err == nil:
+		return counter.Counts[kind], counter.Inited[kind], nil
+	default:
+		return 0, false, err
+	}
+}
+
+// BatchGet 一次查询多个目标的计数，结果中只包含已初始化的目标
+func (m *MongoMapper) BatchGet(ctx context.Context, targetIds []string, targetType action.TargetType, kind Kind) (map[string]int64, error) {
+
+	result := make(map[string]int64, len(targetIds))
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	filter := bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, initedKey(kind): true}
+
+	var counters []*Counter
+
+	err := m.conn.Find(ctx, &counters, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range counters {
+		result[val.TargetId] = val.Counts[kind]
+	}
+
+	return result, nil
+}
+
+// Init 在快照事务中统计并初始化计数，已被其他请求初始化的计数保持不变，返回最终的计数
+func (m *MongoMapper) Init(ctx context.Context, targetId string, targetType action.TargetType, kind Kind, count CountFunc) (int64, error) {
+	return m.set(ctx, targetId, targetType, kind, count, false)
+}
+
+// Reconcile 在快照事务中重新统计并覆盖计数，用于修复明细被直接修改等原因产生的偏差
+func (m *MongoMapper) Reconcile(ctx context.Context, targetId string, targetType action.TargetType, kind Kind, count CountFunc) (int64, error) {
+	return m.set(ctx, targetId, targetType, kind, count, true)
+}
+
+func (m *MongoMapper) set(ctx context.Context, targetId string, targetType action.TargetType, kind Kind, count CountFunc, force bool) (int64, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter Counter
+
+	fn := func(ctx context.Context) error {
+		n, err := count(ctx)
+		if err != nil {
+			return err
+		}
+		return m.conn.FindOneAndUpdateNoCache(ctx, &counter, filter, initUpdate(kind, n, force), opts)
+	}
+
+	err := m.snapshot(ctx, fn)
+	// 计数文档被并发创建时upsert会因唯一索引冲突失败，事务中止后重试一次即可命中该文档
+	if mongo.IsDuplicateKeyError(err) {
+		err = m.snapshot(ctx, fn)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Counts[kind], nil
+}
+
+// BatchInit 在快照事务中统计多个目标并用一次BulkWrite初始化，已初始化的计数保持不变，返回全部目标最终的计数
+func (m *MongoMapper) BatchInit(ctx context.Context, targetIds []string, targetType action.TargetType, kind Kind, count BatchCountFunc) (map[string]int64, error) {
+
+	result := make(map[string]int64, len(targetIds))
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	fn := func(ctx context.Context) error {
+		counts, err := count(ctx)
+		if err != nil {
+			return err
+		}
+
+		models := make([]mongo.WriteModel, 0, len(targetIds))
+		for _, targetId := range targetIds {
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"target_id": targetId, "target_type": targetType}).
+				SetUpdate(initUpdate(kind, counts[targetId], false)).
+				SetUpsert(true))
+		}
+		if _, err = m.conn.Collection.BulkWrite(ctx, models); err != nil {
+			return err
+		}
+
+		// 已初始化的目标保持原值，需要读回最终的计数
+		var counters []*Counter
+		filter := bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType}
+		if err = m.conn.Find(ctx, &counters, filter); err != nil {
+			return err
+		}
+		for _, val := range counters {
+			result[val.TargetId] = val.Counts[kind]
+		}
+		return nil
+	}
+
+	err := m.snapshot(ctx, fn)
+	// 同Init
+	if mongo.IsDuplicateKeyError(err) {
+		err = m.snapshot(ctx, fn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Delete 删除目标的全部计数
+func (m *MongoMapper) Delete(ctx context.Context, targetId string, targetType action.TargetType) error {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	_, err := m.conn.DeleteOneNoCache(ctx, filter)
+	return err
+}
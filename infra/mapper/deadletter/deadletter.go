@@ -0,0 +1,23 @@
+package deadletter
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// DeadLetter 多次重试仍失败的webhook投递，可通过管理接口重放
+type DeadLetter struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	WebhookId string             `bson:"webhook_id" json:"webhook_id"`
+	EventId   string             `bson:"event_id" json:"event_id"`
+	Topic     string             `bson:"topic" json:"topic"`
+	Payload   string             `bson:"payload" json:"payload"`
+	// 事件的目标和用户，用于删除目标或注销用户时清理，早期写入的记录没有这些字段
+	TargetId   string            `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	TargetType action.TargetType `bson:"target_type" json:"target_type"`
+	UserId     string            `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Attempts   int64             `bson:"attempts" json:"attempts"`
+	LastError  string            `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreateAt   time.Time         `bson:"create_at,omitempty" json:"create_at,omitempty"`
+}
@@ -0,0 +1,134 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/pagination"
+	"regexp"
+	"time"
+)
+
+const CollectionName = "webhook_dead_letter"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// GetDeadLetters
+	{Name: "create_at", Keys: bson.D{{Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// DeleteByTarget
+	{Name: "target", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}}},
+	// DeleteByUserId
+	{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}}, Sparse: true},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	InsertOne(ctx context.Context, deadLetter *DeadLetter) error
+	GetDeadLetters(ctx context.Context, options *basic.PaginationOptions) ([]*DeadLetter, string, error)
+	FindOne(ctx context.Context, id string) (*DeadLetter, error)
+	DeleteOne(ctx context.Context, id primitive.ObjectID) error
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func cursorOf(deadLetter *DeadLetter) pagination.Cursor {
+	return pagination.Cursor{ID: deadLetter.ID, CreateAt: deadLetter.CreateAt}
+}
+
+func (m *MongoMapper) InsertOne(ctx context.Context, deadLetter *DeadLetter) error {
+	if deadLetter.ID.IsZero() {
+		deadLetter.ID = primitive.NewObjectID()
+	}
+	deadLetter.CreateAt = time.Now()
+
+	_, err := m.conn.InsertOneNoCache(ctx, deadLetter)
+	return err
+}
+
+func (m *MongoMapper) GetDeadLetters(ctx context.Context, opts *basic.PaginationOptions) ([]*DeadLetter, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var deadLetters []*DeadLetter
+
+	filter := bson.M{}
+
+	err = m.conn.Find(ctx, &deadLetters, filter, p.MakeFindOptions(filter))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Paginate(p, deadLetters, cursorOf)
+}
+
+// FindOne 不存在时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, id string) (*DeadLetter, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var deadLetter DeadLetter
+
+	err = m.conn.FindOneNoCache(ctx, &deadLetter, bson.M{"_id": oid})
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &deadLetter, nil
+	default:
+		return nil, err
+	}
+}
+
+func (m *MongoMapper) DeleteOne(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.conn.DeleteOneNoCache(ctx, bson.M{"_id": id})
+	return err
+}
+
+// CountByTarget 统计目标的死信
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	return m.conn.CountDocuments(ctx, bson.M{"target_id": targetId, "target_type": targetType})
+}
+
+// DeleteByTarget 物理删除目标的死信，返回删除的数量
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	return m.conn.DeleteMany(ctx, bson.M{"target_id": targetId, "target_type": targetType})
+}
+
+// DeleteByUserId 物理删除用户产生的死信，返回删除的数量。
+// 早期写入的记录没有user_id，只能在payload中匹配用户id，这类记录很少，注销时全表扫描可以接受
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"user_id": userId},
+		bson.M{"user_id": bson.M{"$exists": false}, "payload": bson.M{"$regex": regexp.QuoteMeta(`"` + userId + `"`)}},
+	}}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
@@ -0,0 +1,131 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"regexp"
+	"time"
+)
+
+const CollectionName = "webhook_delivery"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// 同一事件对同一webhook只投递一次，outbox重复投递时不会重复入队
+	{Name: "webhook_event_unique", Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "event_id", Value: 1}}, Unique: true},
+	// ClaimDue
+	{Name: "next_attempt_at", Keys: bson.D{{Key: "next_attempt_at", Value: 1}}},
+	// DeleteByTarget
+	{Name: "target", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}}},
+	// DeleteByUserId
+	{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}}, Sparse: true},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	InsertOne(ctx context.Context, delivery *Delivery) error
+	ClaimDue(ctx context.Context, lease time.Duration) (*Delivery, error)
+	Retry(ctx context.Context, id primitive.ObjectID, nextAttemptAt time.Time, cause error) error
+	DeleteOne(ctx context.Context, id primitive.ObjectID) error
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+// InsertOne 入队一次投递，已经入队过的事件直接忽略
+func (m *MongoMapper) InsertOne(ctx context.Context, delivery *Delivery) error {
+	if delivery.ID.IsZero() {
+		delivery.ID = primitive.NewObjectID()
+	}
+	if delivery.CreateAt.IsZero() {
+		delivery.CreateAt = time.Now()
+	}
+	if delivery.NextAttemptAt.IsZero() {
+		delivery.NextAttemptAt = delivery.CreateAt
+	}
+
+	_, err := m.conn.InsertOneNoCache(ctx, delivery)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// ClaimDue 领取一条到期的投递并把下次尝试时间推迟lease，防止被其他实例重复领取，没有时返回nil
+func (m *MongoMapper) ClaimDue(ctx context.Context, lease time.Duration) (*Delivery, error) {
+
+	now := time.Now()
+	filter := bson.M{"next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}, "$inc": bson.M{"attempts": 1}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1}).SetReturnDocument(options.After)
+
+	var delivery Delivery
+
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &delivery, filter, update, opts)
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &delivery, nil
+	default:
+		return nil, err
+	}
+}
+
+func (m *MongoMapper) Retry(ctx context.Context, id primitive.ObjectID, nextAttemptAt time.Time, cause error) error {
+
+	update := bson.M{"$set": bson.M{"next_attempt_at": nextAttemptAt, "last_error": cause.Error()}}
+
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, update)
+	return err
+}
+
+func (m *MongoMapper) DeleteOne(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.conn.DeleteOneNoCache(ctx, bson.M{"_id": id})
+	return err
+}
+
+// CountByTarget 统计目标的待投递的任务
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	return m.conn.CountDocuments(ctx, bson.M{"target_id": targetId, "target_type": targetType})
+}
+
+// DeleteByTarget 物理删除目标的待投递的任务，返回删除的数量
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	return m.conn.DeleteMany(ctx, bson.M{"target_id": targetId, "target_type": targetType})
+}
+
+// DeleteByUserId 物理删除用户产生的投递任务，返回删除的数量。
+// 早期写入的记录没有user_id，只能在payload中匹配用户id，这类记录很少，注销时全表扫描可以接受
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"user_id": userId},
+		bson.M{"user_id": bson.M{"$exists": false}, "payload": bson.M{"$regex": regexp.QuoteMeta(`"` + userId + `"`)}},
+	}}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
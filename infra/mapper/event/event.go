@@ -0,0 +1,37 @@
+package event
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Action 行为的种类
+type Action string
+
+const (
+	Like     Action = "like"
+	Follow   Action = "follow"
+	Share    Action = "share"
+	Favorite Action = "favorite"
+	Block    Action = "block"
+)
+
+// Op 对行为的操作
+type Op string
+
+const (
+	Do     Op = "do"
+	Cancel Op = "cancel"
+)
+
+// Event 只追加不修改，记录每一次行为的变化
+type Event struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Action     Action             `bson:"action" json:"action"`
+	Op         Op                 `bson:"op" json:"op"`
+	TargetId   string             `bson:"target_id,omitempty" json:"target_id"`
+	TargetType action.TargetType  `bson:"target_type" json:"target_type"`
+	UserId     string             `bson:"user_id,omitempty" json:"user_id"`
+	CreateAt   time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
+}
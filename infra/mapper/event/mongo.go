@@ -0,0 +1,128 @@
+package event
+
+import (
+	"context"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/pagination"
+	"time"
+)
+
+const CollectionName = "action_event"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// GetUserEvents
+	{Name: "user_create_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetTargetEvents、CountByTarget、DeleteByTarget
+	{Name: "target_create_at", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	InsertOne(ctx context.Context, act Action, op Op, targetId string, targetType action.TargetType, userId string) error
+	GetUserEvents(ctx context.Context, userId string, act Action, options *basic.PaginationOptions) ([]*Event, string, error)
+	GetTargetEvents(ctx context.Context, targetId string, targetType action.TargetType, act Action, options *basic.PaginationOptions) ([]*Event, string, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func cursorOf(event *Event) pagination.Cursor {
+	return pagination.Cursor{ID: event.ID, CreateAt: event.CreateAt}
+}
+
+func (m *MongoMapper) InsertOne(ctx context.Context, act Action, op Op, targetId string, targetType action.TargetType, userId string) error {
+
+	newEvent := &Event{
+		ID:         primitive.NewObjectID(),
+		Action:     act,
+		Op:         op,
+		TargetId:   targetId,
+		TargetType: targetType,
+		UserId:     userId,
+		CreateAt:   time.Now(),
+	}
+
+	_, err := m.conn.InsertOneNoCache(ctx, newEvent)
+	return err
+}
+
+// GetUserEvents 按时间倒序返回用户的行为记录，act为空时返回全部种类
+func (m *MongoMapper) GetUserEvents(ctx context.Context, userId string, act Action, opts *basic.PaginationOptions) ([]*Event, string, error) {
+
+	filter := bson.M{"user_id": userId}
+	if act != "" {
+		filter["action"] = act
+	}
+
+	return m.find(ctx, filter, opts)
+}
+
+// GetTargetEvents 按时间倒序返回目标收到的行为记录，act为空时返回全部种类
+func (m *MongoMapper) GetTargetEvents(ctx context.Context, targetId string, targetType action.TargetType, act Action, opts *basic.PaginationOptions) ([]*Event, string, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+	if act != "" {
+		filter["action"] = act
+	}
+
+	return m.find(ctx, filter, opts)
+}
+
+// DeleteByUserId 物理删除用户产生的全部事件，以及其他用户关注、拉黑该用户等以该用户为目标的事件
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"user_id": userId},
+		bson.M{"target_id": userId, "target_type": action.TargetType_USER},
+	}}
+	return m.conn.DeleteMany(ctx, filter)
+}
+
+// CountByTarget 统计指向目标的全部事件
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	return m.conn.CountDocuments(ctx, bson.M{"target_id": targetId, "target_type": targetType})
+}
+
+// DeleteByTarget 物理删除指向目标的全部事件
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	return m.conn.DeleteMany(ctx, bson.M{"target_id": targetId, "target_type": targetType})
+}
+
+func (m *MongoMapper) find(ctx context.Context, filter bson.M, opts *basic.PaginationOptions) ([]*Event, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var events []*Event
+
+	err = m.conn.Find(ctx, &events, filter, p.MakeFindOptions(filter))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Paginate(p, events, cursorOf)
+}
@@ -0,0 +1,20 @@
+package favorite
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Favorite 每个用户对同一目标只有一条收藏，收藏在CollectionId对应的收藏夹中
+type Favorite struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TargetId     string             `bson:"target_id,omitempty" json:"target_id"`
+	TargetType   action.TargetType  `bson:"target_type" json:"target_type"`
+	UserId       string             `bson:"user_id,omitempty" json:"user_id"`
+	CollectionId string             `bson:"collection_id" json:"collection_id"` // 为空表示未分组
+	IsCancel     bool               `bson:"is_cancel" json:"is_cancel"`
+	CreateAt     time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
+	UpdateAt     time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
+	DeleteAt     time.Time          `bson:"delete_at,omitempty" json:"delete_at,omitempty"`
+}
@@ -0,0 +1,352 @@
+package favorite
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/pagination"
+	"meowcloud-action/infra/mapper/upsert"
+	"time"
+)
+
+const CollectionName = "favorite"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// (target_id, target_type, user_id)唯一，保证并发upsert时只会留下一条记录
+	{Name: "target_user_unique", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "user_id", Value: 1}}, Unique: true},
+	// CountFavorites
+	{Name: "target_create_at", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetByCollection、CountByCollection
+	{Name: "user_collection_create_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "collection_id", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// IterateByUserId、DeleteByUserId
+	{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: 1}}},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string, collectionId string) (*Favorite, error)
+	CancelFavorite(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
+	FindOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*Favorite, error)
+	BatchIsFavorited(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error)
+	CountFavorites(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	BatchCountFavorites(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error)
+	GetByCollection(ctx context.Context, userId string, collectionId string, options *basic.PaginationOptions) ([]*Favorite, int64, string, error)
+	CountByCollection(ctx context.Context, userId string, collectionId string) (int64, error)
+	CountByCollections(ctx context.Context, userId string) (map[string]int64, error)
+	MoveCollection(ctx context.Context, userId string, from string, to string) (int64, error)
+	IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Favorite) error) error
+	DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Favorite, error)
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func cursorOf(favorite *Favorite) pagination.Cursor {
+	return pagination.Cursor{ID: favorite.ID, CreateAt: favorite.CreateAt}
+}
+
+// InsertOne 原子地upsert一条收藏记录，已收藏时移动到collectionId，返回修改前的记录，原先不存在时返回nil
+func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string, collectionId string) (*Favorite, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
+
+	now := time.Now()
+	update := bson.M{
+		"$set":         bson.M{"is_cancel": false, "collection_id": collectionId, "update_at": now},
+		"$unset":       bson.M{"delete_at": ""},
+		"$setOnInsert": bson.M{"create_at": now},
+	}
+	var old Favorite
+
+	err := upsert.FindOneAndUpsert(ctx, m.conn, &old, filter, update)
+
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &old, nil
+	default:
+		return nil, err
+	}
+}
+
+// CancelFavorite 原子地取消一条生效中的收藏记录，返回值表示是否确实取消了记录
+func (m *MongoMapper) CancelFavorite(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId, "is_cancel": false}
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"is_cancel": true, "update_at": now, "delete_at": now}}
+
+	var old Favorite
+
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &old, filter, update)
+
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return false, nil
+	case err == nil:
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// FindOne 返回生效中的收藏记录，未收藏时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*Favorite, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId, "is_cancel": false}
+
+	var favorite Favorite
+
+	err := m.conn.FindOneNoCache(ctx, &favorite, filter)
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &favorite, nil
+	default:
+		return nil, err
+	}
+}
+
+// BatchIsFavorited 用一次$in查询返回userId对每个targetId的状态，不存在的targetId为false
+func (m *MongoMapper) BatchIsFavorited(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error) {
+
+	result := make(map[string]bool, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = false
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	filter := bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, "user_id": userId, "is_cancel": false}
+
+	var favorites []*Favorite
+
+	err := m.conn.Find(ctx, &favorites, filter, options.Find().SetProjection(bson.M{"target_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range favorites {
+		result[val.TargetId] = true
+	}
+
+	return result, nil
+}
+
+func (m *MongoMapper) CountFavorites(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// BatchCountFavorites 用一次聚合统计多个目标的数量，没有记录的targetId为0
+func (m *MongoMapper) BatchCountFavorites(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error) {
+
+	result := make(map[string]int64, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = 0
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, "is_cancel": false}}},
+		{{Key: "$group", Value: bson.M{"_id": "$target_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	var counts []struct {
+		TargetId string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range counts {
+		result[val.TargetId] = val.Count
+	}
+
+	return result, nil
+}
+
+// GetByCollection 分页查询收藏夹中的内容，collectionId为空时查询未分组的收藏
+func (m *MongoMapper) GetByCollection(ctx context.Context, userId string, collectionId string, opts *basic.PaginationOptions) ([]*Favorite, int64, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	var favorites []*Favorite
+
+	filter := bson.M{"user_id": userId, "collection_id": collectionId, "is_cancel": false}
+
+	err = m.conn.Find(ctx, &favorites, filter, p.MakeFindOptions(filter))
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	favorites, lastToken, err := pagination.Paginate(p, favorites, cursorOf)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	total, err := m.CountByCollection(ctx, userId, collectionId)
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return favorites, total, lastToken, nil
+}
+
+func (m *MongoMapper) CountByCollection(ctx context.Context, userId string, collectionId string) (int64, error) {
+	filter := bson.M{"user_id": userId, "collection_id": collectionId, "is_cancel": false}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// CountByCollections 统计用户每个收藏夹中的数量，key为collectionId，未分组的为空字符串
+func (m *MongoMapper) CountByCollections(ctx context.Context, userId string) (map[string]int64, error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userId, "is_cancel": false}}},
+		{{Key: "$group", Value: bson.M{"_id": "$collection_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	var counts []struct {
+		CollectionId string `bson:"_id"`
+		Count        int64  `bson:"count"`
+	}
+
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(counts))
+	for _, val := range counts {
+		result[val.CollectionId] = val.Count
+	}
+
+	return result, nil
+}
+
+// MoveCollection 把from中的全部收藏移动到to，包括已取消的，返回移动的数量
+func (m *MongoMapper) MoveCollection(ctx context.Context, userId string, from string, to string) (int64, error) {
+
+	filter := bson.M{"user_id": userId, "collection_id": from}
+	update := bson.M{"$set": bson.M{"collection_id": to, "update_at": time.Now()}}
+
+	res, err := m.conn.UpdateManyNoCache(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// IterateByUserId 按_id顺序逐条遍历用户afterId之后的最多limit条记录，包括已取消的，afterId为零值时从头开始，fn返回错误时停止遍历
+func (m *MongoMapper) IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Favorite) error) error {
+
+	filter := bson.M{"user_id": userId}
+	if !afterId.IsZero() {
+		filter["_id"] = bson.M{"$gt": afterId}
+	}
+
+	cursor, err := m.conn.Collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(limit))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var favorite Favorite
+		if err = cursor.Decode(&favorite); err != nil {
+			return err
+		}
+		if err = fn(&favorite); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// DeleteByUserId 按_id顺序物理删除用户的至多limit条记录，返回被删除的记录，用于修正计数，返回空表示已经删除完毕。
+// 只按查到的_id删除，查询之后并发新建的记录会被保留，避免删除了没有修正计数的记录
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Favorite, error) {
+
+	filter := bson.M{"user_id": userId}
+
+	var favorites []*Favorite
+
+	err := m.conn.Find(ctx, &favorites, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(favorites) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(favorites))
+	for _, val := range favorites {
+		ids = append(ids, val.ID)
+	}
+
+	_, err = m.conn.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return favorites, nil
+}
+
+// CountByTarget 统计指向目标的全部记录，包括已取消的
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// DeleteByTarget 物理删除指向目标的全部记录，返回删除的数量
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
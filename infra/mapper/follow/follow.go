@@ -2,17 +2,71 @@ package follow
 
 import (
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
+// Status 关注私密账号时关注请求的状态，pending只能流转到active、rejected或withdrawn。
+// 关注是否生效仍以IsCancel为准，未处于active的请求IsCancel为true，因此计数和列表天然只包含生效的关注
+type Status string
+
+const (
+	StatusActive    Status = "active"
+	StatusPending   Status = "pending"
+	StatusRejected  Status = "rejected"
+	StatusWithdrawn Status = "withdrawn"
+)
+
+// requestTransitions 关注请求允许的状态流转，key为当前状态，value为可以流转到的状态
+var requestTransitions = map[Status][]Status{
+	StatusPending: {StatusActive, StatusRejected, StatusWithdrawn},
+}
+
+// canTransit 返回请求能否从from流转到to
+func canTransit(from Status, to Status) bool {
+	for _, s := range requestTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// sourcesOf 返回可以流转到to的所有状态，按状态定义的顺序排列
+func sourcesOf(to Status) []Status {
+	var sources []Status
+	for _, from := range []Status{StatusActive, StatusPending, StatusRejected, StatusWithdrawn} {
+		if canTransit(from, to) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// transitUpdate 返回流转到to时需要写入的字段，流转到active时关注随之生效
+func transitUpdate(to Status, now time.Time) bson.M {
+	set := bson.M{"status": to, "update_at": now}
+	if to == StatusActive {
+		set["is_cancel"] = false
+	}
+	return set
+}
+
 type Follow struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	TargetId   string             `bson:"target_id,omitempty" json:"target_id"`
 	TargetType action.TargetType  `bson:"target_type" json:"target_type"`
 	UserId     string             `bson:"user_id,omitempty" json:"user_id"`
 	IsCancel   bool               `bson:"is_cancel" json:"is_cancel"`
+	Status     Status             `bson:"status,omitempty" json:"status,omitempty"`
 	CreateAt   time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
 	UpdateAt   time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
 	DeleteAt   time.Time          `bson:"delete_at,omitempty" json:"delete_at,omitempty"`
 }
+
+// CommonFollowers 共同关注的统计结果，会被序列化到缓存中
+type CommonFollowers struct {
+	UserIds []string `json:"user_ids"`
+	Total   int64    `json:"total"`
+}
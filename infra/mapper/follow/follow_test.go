@@ -0,0 +1,61 @@
+package follow
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCanTransit(t *testing.T) {
+	tests := []struct {
+		name string
+		from Status
+		to   Status
+		want bool
+	}{
+		{"待处理通过", StatusPending, StatusActive, true},
+		{"待处理拒绝", StatusPending, StatusRejected, true},
+		{"待处理撤回", StatusPending, StatusWithdrawn, true},
+		{"待处理不变", StatusPending, StatusPending, false},
+		{"已拒绝不能通过", StatusRejected, StatusActive, false},
+		{"已撤回不能通过", StatusWithdrawn, StatusActive, false},
+		{"已生效不能撤回", StatusActive, StatusWithdrawn, false},
+		{"已生效不能拒绝", StatusActive, StatusRejected, false},
+		{"已拒绝不能撤回", StatusRejected, StatusWithdrawn, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canTransit(tt.from, tt.to); got != tt.want {
+				t.Errorf("canTransit(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourcesOf(t *testing.T) {
+	for _, to := range []Status{StatusActive, StatusRejected, StatusWithdrawn} {
+		if got := sourcesOf(to); !reflect.DeepEqual(got, []Status{StatusPending}) {
+			t.Errorf("sourcesOf(%s) = %v, want [pending]", to, got)
+		}
+	}
+	if got := sourcesOf(StatusPending); len(got) != 0 {
+		t.Errorf("sourcesOf(pending) = %v, want []", got)
+	}
+}
+
+func TestTransitUpdate(t *testing.T) {
+	now := time.Now()
+
+	set := transitUpdate(StatusActive, now)
+	if set["status"] != StatusActive || set["is_cancel"] != false || set["update_at"] != now {
+		t.Errorf("transitUpdate(active) = %v", set)
+	}
+
+	// 拒绝和撤回后关注仍不生效，不能修改is_cancel
+	for _, to := range []Status{StatusRejected, StatusWithdrawn} {
+		set := transitUpdate(to, now)
+		if _, ok := set["is_cancel"]; ok || set["status"] != to {
+			t.Errorf("transitUpdate(%s) = %v", to, set)
+		}
+	}
+}
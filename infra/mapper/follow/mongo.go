@@ -3,75 +3,125 @@ package follow
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/cache"
 	"github.com/zeromicro/go-zero/core/stores/monc"
+	"github.com/zeromicro/go-zero/core/syncx"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/pagination"
+	"meowcloud-action/infra/mapper/recommend"
+	"meowcloud-action/infra/mapper/upsert"
 	"time"
 )
 
 const prefixFollowCacheKey = "cache:follow"
 const CollectionName = "follow"
 
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// (target_id, target_type, user_id)唯一，保证并发upsert时只会留下一条记录
+	{Name: "target_user_unique", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "user_id", Value: 1}}, Unique: true},
+	// GetFollowedUsers、CountFollows
+	{Name: "target_create_at", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetUserFollowed、CountFollowsByUserId
+	{Name: "user_create_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetIncomingRequests
+	{Name: "target_status_create_at", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "status", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetOutgoingRequests
+	{Name: "user_status_create_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "status", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// IterateByUserId、DeleteByUserId
+	{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: 1}}},
+}
+
 // 用于检查接口是否实现
 var _ IMongoMapper = (*MongoMapper)(nil)
 
 type IMongoMapper interface {
-	InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) error
+	InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
 	IsFollowed(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
-	CancelFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) error
+	BatchIsFollowed(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error)
+	CancelFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
 	CountFollows(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
-	GetFollowedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) ([]*Follow, int64, error)
-	GetUserFollowed(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) ([]*Follow, int64, error)
+	BatchCountFollows(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error)
+	GetFollowedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) ([]*Follow, string, error)
+	GetUserFollowed(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) ([]*Follow, int64, string, error)
 	CountFollowsByUserId(ctx context.Context, targetType action.TargetType, userId string) (int64, error)
+	IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Follow) error) error
+	DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Follow, error)
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	BatchIsFollowedBy(ctx context.Context, targetId string, targetType action.TargetType, userIds []string) (map[string]bool, error)
+	GetMutualFollows(ctx context.Context, userId string, options *basic.PaginationOptions) ([]*Follow, int64, string, error)
+	GetFollowingNotFollowedBack(ctx context.Context, userId string, options *basic.PaginationOptions) ([]*Follow, int64, string, error)
+	GetFollowersNotFollowedBack(ctx context.Context, userId string, options *basic.PaginationOptions) ([]*Follow, int64, string, error)
+	GetCommonFollowers(ctx context.Context, viewerId string, targetId string, limit int64) (*CommonFollowers, error)
+	GetFriendsOfFriends(ctx context.Context, userId string, targetType action.TargetType, sampleSize int64, limit int64) ([]*recommend.Candidate, error)
+	GetHeavyUsers(ctx context.Context, minFollowing int64) ([]string, error)
+	RequestFollow(ctx context.Context, targetId string, userId string) (bool, error)
+	ApproveRequest(ctx context.Context, targetId string, userId string) (bool, error)
+	RejectRequest(ctx context.Context, targetId string, userId string) (bool, error)
+	WithdrawRequest(ctx context.Context, targetId string, userId string) (bool, error)
+	GetIncomingRequests(ctx context.Context, targetId string, options *basic.PaginationOptions) ([]*Follow, int64, string, error)
+	GetOutgoingRequests(ctx context.Context, userId string, options *basic.PaginationOptions) ([]*Follow, int64, string, error)
 }
 
 type MongoMapper struct {
-	conn *monc.Model
+	conn  *monc.Model
+	cache cache.Cache
 }
 
 func NewMongoMapper() IMongoMapper {
 	aConfig := config.Get()
 	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
 	return &MongoMapper{
 		conn: conn,
+		// 聚合结果只需短时间缓存，不使用monc默认的过期时间
+		cache: cache.New(aConfig.Cache, syncx.NewSingleFlight(), cache.NewStat(CollectionName), monc.ErrNotFound, cache.WithExpiry(aConfig.Follow.CommonTTL)),
 	}
 }
 
-func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) error {
+func cursorOf(follow *Follow) pagination.Cursor {
+	return pagination.Cursor{ID: follow.ID, CreateAt: follow.CreateAt}
+}
+
+// InsertOne 原子地upsert一条follow记录，返回值表示状态是否发生变化，已处于生效状态时返回false
+func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error) {
 
 	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
 
-	var follow Follow
+	now := time.Now()
+	update := bson.M{
+		"$set":         bson.M{"is_cancel": false, "status": StatusActive, "update_at": now},
+		"$unset":       bson.M{"delete_at": ""},
+		"$setOnInsert": bson.M{"create_at": now},
+	}
+	var old Follow
 
-	err := m.conn.FindOne(ctx, targetId+targetType.String()+userId, &follow, filter)
+	err := upsert.FindOneAndUpsert(ctx, m.conn, &old, filter, update)
 
 	switch {
-	// 已经存在则修改isCancel状态
-	case err == nil:
-		follow.IsCancel = false
-		_, err = m.conn.ReplaceOneNoCache(ctx, filter, follow)
 	// 不存在则新建
 	case errors.Is(err, monc.ErrNotFound):
-		newFollow := &Follow{
-			ID:         primitive.NewObjectID(),
-			TargetId:   targetId,
-			TargetType: targetType,
-			UserId:     userId,
-			IsCancel:   false,
-			CreateAt:   time.Now(),
-			UpdateAt:   time.Now(),
-		}
-		key := prefixFollowCacheKey + newFollow.TargetId + newFollow.ID.Hex()
-		_, err = m.conn.InsertOne(ctx, key, newFollow)
+		return true, nil
+	// 已经存在则只有原先被取消时才算生效
+	case err == nil:
+		return old.IsCancel, nil
+	default:
+		return false, err
 	}
-	return err
-
 }
 
+// IsFollowed 返回userId是否处于关注状态，早期版本返回的是IsCancel，语义相反
 func (m *MongoMapper) IsFollowed(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error) {
 
 	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
@@ -83,28 +133,59 @@ func (m *MongoMapper) IsFollowed(ctx context.Context, targetId string, targetTyp
 	case errors.Is(err, monc.ErrNotFound):
 		return false, nil
 	case err == nil:
-		return follow.IsCancel, nil
+		return !follow.IsCancel, nil
 	default:
 		return false, err
 	}
 }
 
-func (m *MongoMapper) CancelFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) error {
+// BatchIsFollowed 用一次$in查询返回userId对每个targetId的状态，不存在的targetId为false
+func (m *MongoMapper) BatchIsFollowed(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error) {
 
-	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
+	result := make(map[string]bool, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = false
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
 
-	var follow Follow
+	filter := bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, "user_id": userId, "is_cancel": false}
 
-	err := m.conn.FindOneNoCache(ctx, &follow, filter)
+	var follows []*Follow
+
+	err := m.conn.Find(ctx, &follows, filter, options.Find().SetProjection(bson.M{"target_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range follows {
+		result[val.TargetId] = true
+	}
+
+	return result, nil
+}
+
+// CancelFollow 原子地取消一条生效中的follow记录，返回值表示是否确实取消了记录
+func (m *MongoMapper) CancelFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId, "is_cancel": false}
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"is_cancel": true, "update_at": now, "delete_at": now}}
+
+	var old Follow
+
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &old, filter, update)
 
 	switch {
 	case errors.Is(err, monc.ErrNotFound):
-		return nil
+		return false, nil
 	case err == nil:
-		follow.IsCancel = true
-		_, err = m.conn.ReplaceOne(ctx, "", filter, follow)
+		return true, nil
+	default:
+		return false, err
 	}
-	return err
 }
 
 func (m *MongoMapper) CountFollows(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
@@ -121,59 +202,92 @@ func (m *MongoMapper) CountFollows(ctx context.Context, targetId string, targetT
 	return count, nil
 }
 
-func (m *MongoMapper) GetFollowedUsers(ctx context.Context, targetId string, targetType action.TargetType, opts *basic.PaginationOptions) ([]*Follow, int64, error) {
-	pageSize := *opts.Limit
-	skip := (*opts.Page - 1) * pageSize
+// BatchCountFollows 用一次聚合统计多个目标的数量，没有记录的targetId为0
+func (m *MongoMapper) BatchCountFollows(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error) {
 
-	follows := make([]*Follow, pageSize)
+	result := make(map[string]int64, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = 0
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
 
-	filter := bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, "is_cancel": false}}},
+		{{Key: "$group", Value: bson.M{"_id": "$target_id", "count": bson.M{"$sum": 1}}}},
+	}
 
-	err := m.conn.Find(ctx, &follows, filter, &options.FindOptions{
-		Limit: opts.Limit,
-		Skip:  &skip,
-		// 按时间降序，最新的在最前面
-		Sort: bson.M{"create_at": -1},
-	})
+	var counts []struct {
+		TargetId string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+
+	for _, val := range counts {
+		result[val.TargetId] = val.Count
 	}
 
-	total, err := m.CountFollows(ctx, targetId, targetType)
+	return result, nil
+}
 
+func (m *MongoMapper) GetFollowedUsers(ctx context.Context, targetId string, targetType action.TargetType, opts *basic.PaginationOptions) ([]*Follow, string, error) {
+	p, err := pagination.NewPaginator(opts)
 	if err != nil {
-		return nil, 0, err
+		return nil, "", err
 	}
-	return follows, total, err
+
+	var follows []*Follow
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false}
+
+	// 用户的关注者中去掉与其存在拉黑关系的用户，拉黑时已取消双向关注，这里兜底拉黑前后并发产生的关注
+	if targetType == action.TargetType_USER {
+		err = m.conn.Aggregate(ctx, &follows, p.MakePipeline(filter, eitherBlockedStages("user_id", targetId)...))
+	} else {
+		err = m.conn.Find(ctx, &follows, filter, p.MakeFindOptions(filter))
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pagination.Paginate(p, follows, cursorOf)
 }
 
-func (m *MongoMapper) GetUserFollowed(ctx context.Context, targetType action.TargetType, userId string, opts *basic.PaginationOptions) ([]*Follow, int64, error) {
-	pageSize := *opts.Limit
-	skip := (*opts.Page - 1) * pageSize
+func (m *MongoMapper) GetUserFollowed(ctx context.Context, targetType action.TargetType, userId string, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
 
-	follows := make([]*Follow, pageSize)
+	var follows []*Follow
 
 	filter := bson.M{"target_type": targetType, "user_id": userId, "is_cancel": false}
 
-	err := m.conn.Find(ctx, &follows, filter, &options.FindOptions{
-		Limit: opts.Limit,
-		Skip:  &skip,
-		// 按时间降序，最新的在最前面
-		Sort: bson.M{"create_at": -1},
-	})
+	err = m.conn.Find(ctx, &follows, filter, p.MakeFindOptions(filter))
+
+	if err != nil {
+		return nil, 0, "", err
+	}
 
+	follows, lastToken, err := pagination.Paginate(p, follows, cursorOf)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
 	total, err := m.CountFollowsByUserId(ctx, targetType, userId)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
-	return follows, total, err
+	return follows, total, lastToken, err
 }
 
 func (m *MongoMapper) CountFollowsByUserId(ctx context.Context, targetType action.TargetType, userId string) (int64, error) {
@@ -189,3 +303,470 @@ func (m *MongoMapper) CountFollowsByUserId(ctx context.Context, targetType actio
 
 	return count, nil
 }
+
+// DeleteByUserId 按_id顺序物理删除用户的至多limit条记录，返回被删除的记录，用于修正计数，返回空表示已经删除完毕。
+// 只按查到的_id删除，查询之后并发新建的记录会被保留，避免删除了没有修正计数的记录
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Follow, error) {
+
+	filter := bson.M{"user_id": userId}
+
+	var follows []*Follow
+
+	err := m.conn.Find(ctx, &follows, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(follows) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(follows))
+	for _, val := range follows {
+		ids = append(ids, val.ID)
+	}
+
+	_, err = m.conn.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return follows, nil
+}
+
+// CountByTarget 统计指向目标的全部记录，包括已取消的
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// DeleteByTarget 物理删除指向目标的全部记录，返回删除的数量
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
+
+// IterateByUserId 按_id顺序逐条遍历用户afterId之后的最多limit条记录，包括已取消的，afterId为零值时从头开始，fn返回错误时停止遍历
+func (m *MongoMapper) IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Follow) error) error {
+
+	filter := bson.M{"user_id": userId}
+	if !afterId.IsZero() {
+		filter["_id"] = bson.M{"$gt": afterId}
+	}
+
+	cursor, err := m.conn.Collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(limit))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var follow Follow
+		if err = cursor.Decode(&follow); err != nil {
+			return err
+		}
+		if err = fn(&follow); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// BatchIsFollowedBy 返回userIds中每个用户是否关注了targetId，与BatchIsFollowed方向相反
+func (m *MongoMapper) BatchIsFollowedBy(ctx context.Context, targetId string, targetType action.TargetType, userIds []string) (map[string]bool, error) {
+
+	result := make(map[string]bool, len(userIds))
+	for _, userId := range userIds {
+		result[userId] = false
+	}
+	if len(userIds) == 0 {
+		return result, nil
+	}
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": bson.M{"$in": userIds}, "is_cancel": false}
+
+	var follows []*Follow
+
+	err := m.conn.Find(ctx, &follows, filter, options.Find().SetProjection(bson.M{"user_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range follows {
+		result[val.UserId] = true
+	}
+
+	return result, nil
+}
+
+// reciprocalStages 关联查询记录中的另一个用户与userId之间是否存在反向关注，reciprocated决定保留存在反向关注的还是不存在的记录
+// otherField为另一个用户所在的字段，target_id表示userId关注的用户，user_id表示关注userId的用户
+func reciprocalStages(otherField string, userId string, reciprocated bool) []bson.D {
+	foreignField, selfField := "user_id", "target_id"
+	if otherField == "user_id" {
+		foreignField, selfField = "target_id", "user_id"
+	}
+
+	return []bson.D{
+		{{Key: "$lookup", Value: bson.M{
+			"from":         CollectionName,
+			"localField":   otherField,
+			"foreignField": foreignField,
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{selfField: userId, "target_type": action.TargetType_USER, "is_cancel": false}},
+				bson.M{"$limit": 1},
+			},
+			"as": "back",
+		}}},
+		{{Key: "$match", Value: bson.M{"back.0": bson.M{"$exists": reciprocated}}}},
+		{{Key: "$project", Value: bson.M{"back": 0}}},
+	}
+}
+
+// eitherBlockedStages 去掉otherField中的用户与userId之间任意一方拉黑了另一方的记录
+func eitherBlockedStages(otherField string, userId string) []bson.D {
+	return []bson.D{
+		{{Key: "$lookup", Value: bson.M{
+			"from":         block.CollectionName,
+			"localField":   otherField,
+			"foreignField": "target_id",
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"user_id": userId, "is_cancel": false}},
+				bson.M{"$limit": 1},
+			},
+			"as": "blocked",
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         block.CollectionName,
+			"localField":   otherField,
+			"foreignField": "user_id",
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"target_id": userId, "is_cancel": false}},
+				bson.M{"$limit": 1},
+			},
+			"as": "blocker",
+		}}},
+		{{Key: "$match", Value: bson.M{"blocked.0": bson.M{"$exists": false}, "blocker.0": bson.M{"$exists": false}}}},
+		{{Key: "$project", Value: bson.M{"blocked": 0, "blocker": 0}}},
+	}
+}
+
+// GetMutualFollows 分页返回userId关注的用户中同时关注了userId的记录，及其总数
+func (m *MongoMapper) GetMutualFollows(ctx context.Context, userId string, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	filter := func() bson.M {
+		return bson.M{"user_id": userId, "target_type": action.TargetType_USER, "is_cancel": false}
+	}
+	return m.getReciprocal(ctx, filter, reciprocalStages("target_id", userId, true), opts)
+}
+
+// GetFollowingNotFollowedBack 分页返回userId关注的用户中没有回关userId的记录，及其总数
+func (m *MongoMapper) GetFollowingNotFollowedBack(ctx context.Context, userId string, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	filter := func() bson.M {
+		return bson.M{"user_id": userId, "target_type": action.TargetType_USER, "is_cancel": false}
+	}
+	return m.getReciprocal(ctx, filter, reciprocalStages("target_id", userId, false), opts)
+}
+
+// GetFollowersNotFollowedBack 分页返回关注了userId但userId没有回关的记录，及其总数
+func (m *MongoMapper) GetFollowersNotFollowedBack(ctx context.Context, userId string, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	filter := func() bson.M {
+		return bson.M{"target_id": userId, "target_type": action.TargetType_USER, "is_cancel": false}
+	}
+	return m.getReciprocal(ctx, filter, reciprocalStages("user_id", userId, false), opts)
+}
+
+// getReciprocal 按stages关联过滤后分页，filter每次调用返回新的条件，因为分页会在原地追加游标条件
+func (m *MongoMapper) getReciprocal(ctx context.Context, filter func() bson.M, stages []bson.D, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	var follows []*Follow
+
+	err = m.conn.Aggregate(ctx, &follows, p.MakePipeline(filter(), stages...))
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	follows, lastToken, err := pagination.Paginate(p, follows, cursorOf)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	total, err := m.countPipeline(ctx, filter(), stages)
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return follows, total, lastToken, nil
+}
+
+// countPipeline 统计filter经过stages过滤后剩余的记录数
+func (m *MongoMapper) countPipeline(ctx context.Context, filter bson.M, stages []bson.D) (int64, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	pipeline = append(pipeline, stages...)
+	pipeline = append(pipeline, bson.D{{Key: "$count", Value: "total"}})
+
+	var counts []struct {
+		Total int64 `bson:"total"`
+	}
+
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(counts) == 0 {
+		return 0, nil
+	}
+
+	return counts[0].Total, nil
+}
+
+// GetCommonFollowers 统计viewerId关注的用户中同时关注了targetId的用户，返回总数与viewerId最近关注的limit个，结果按(viewerId, targetId)短时间缓存
+func (m *MongoMapper) GetCommonFollowers(ctx context.Context, viewerId string, targetId string, limit int64) (*CommonFollowers, error) {
+	key := fmt.Sprintf("%s:common:%s:%s:%d", prefixFollowCacheKey, viewerId, targetId, limit)
+
+	var result CommonFollowers
+
+	err := m.cache.TakeCtx(ctx, &result, key, func(v any) error {
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"user_id": viewerId, "target_type": action.TargetType_USER, "is_cancel": false}}}}
+		// viewerId关注的用户是否关注了targetId，与判断回关的关联方式相同
+		pipeline = append(pipeline, reciprocalStages("target_id", targetId, true)...)
+		pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+			"users": bson.A{
+				bson.M{"$sort": bson.D{{Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+				bson.M{"$limit": limit},
+				bson.M{"$project": bson.M{"target_id": 1}},
+			},
+			"total": bson.A{bson.M{"$count": "total"}},
+		}}})
+
+		var facets []struct {
+			Users []struct {
+				TargetId string `bson:"target_id"`
+			} `bson:"users"`
+			Total []struct {
+				Total int64 `bson:"total"`
+			} `bson:"total"`
+		}
+
+		if err := m.conn.Aggregate(ctx, &facets, pipeline); err != nil {
+			return err
+		}
+
+		common := v.(*CommonFollowers)
+		common.UserIds = []string{}
+		if len(facets) == 0 {
+			return nil
+		}
+		for _, val := range facets[0].Users {
+			common.UserIds = append(common.UserIds, val.TargetId)
+		}
+		if len(facets[0].Total) > 0 {
+			common.Total = facets[0].Total[0].Total
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetFriendsOfFriends 从userId最近关注的sampleSize个用户出发，统计他们关注的targetType对象被关注的次数，
+// 排除userId自己、已经关注的对象和与userId存在拉黑关系的用户，按次数降序返回前limit个
+func (m *MongoMapper) GetFriendsOfFriends(ctx context.Context, userId string, targetType action.TargetType, sampleSize int64, limit int64) ([]*recommend.Candidate, error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userId, "target_type": action.TargetType_USER, "is_cancel": false}}},
+		{{Key: "$sort", Value: bson.D{{Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$limit", Value: sampleSize}},
+		// 关注的人所关注的targetType对象
+		{{Key: "$lookup", Value: bson.M{
+			"from":         CollectionName,
+			"localField":   "target_id",
+			"foreignField": "user_id",
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"target_type": targetType, "is_cancel": false}},
+				bson.M{"$project": bson.M{"_id": 0, "target_id": 1}},
+			},
+			"as": "next",
+		}}},
+		{{Key: "$unwind", Value: "$next"}},
+		{{Key: "$match", Value: bson.M{"next.target_id": bson.M{"$ne": userId}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$next.target_id", "score": bson.M{"$sum": 1}}}},
+		// 排除已经关注的对象
+		{{Key: "$lookup", Value: bson.M{
+			"from":         CollectionName,
+			"localField":   "_id",
+			"foreignField": "target_id",
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"user_id": userId, "target_type": targetType, "is_cancel": false}},
+				bson.M{"$limit": 1},
+			},
+			"as": "followed",
+		}}},
+		{{Key: "$match", Value: bson.M{"followed.0": bson.M{"$exists": false}}}},
+	}
+	// 推荐用户时排除与userId存在拉黑关系的用户，任意一方拉黑都算
+	if targetType == action.TargetType_USER {
+		pipeline = append(pipeline, eitherBlockedStages("_id", userId)...)
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+		bson.D{{Key: "$project", Value: bson.M{"score": 1}}},
+	)
+
+	var candidates []*recommend.Candidate
+
+	err := m.conn.Aggregate(ctx, &candidates, pipeline, options.Aggregate().SetAllowDiskUse(true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// GetHeavyUsers 返回关注用户数不少于minFollowing的用户，供离线任务预计算推荐
+func (m *MongoMapper) GetHeavyUsers(ctx context.Context, minFollowing int64) ([]string, error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_type": action.TargetType_USER, "is_cancel": false}}},
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gte": minFollowing}}}},
+	}
+
+	var users []struct {
+		UserId string `bson:"_id"`
+	}
+
+	err := m.conn.Aggregate(ctx, &users, pipeline, options.Aggregate().SetAllowDiskUse(true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make([]string, 0, len(users))
+	for _, val := range users {
+		userIds = append(userIds, val.UserId)
+	}
+
+	return userIds, nil
+}
+
+// RequestFollow 对私密用户发起关注请求，已关注或已有待处理的请求时返回false。
+// 过滤条件只匹配未生效且不在待处理状态的记录，其余情况upsert会因唯一索引冲突而失败，从而保证原子性
+func (m *MongoMapper) RequestFollow(ctx context.Context, targetId string, userId string) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": action.TargetType_USER, "user_id": userId, "is_cancel": true, "status": bson.M{"$ne": StatusPending}}
+
+	now := time.Now()
+	// 重新发起的请求按新的时间排序
+	update := bson.M{
+		"$set":   bson.M{"status": StatusPending, "create_at": now, "update_at": now},
+		"$unset": bson.M{"delete_at": ""},
+	}
+
+	_, err := m.conn.UpdateOneNoCache(ctx, filter, update, options.Update().SetUpsert(true))
+
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		return false, nil
+	case err == nil:
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// ApproveRequest 通过待处理的请求，关注随之生效
+func (m *MongoMapper) ApproveRequest(ctx context.Context, targetId string, userId string) (bool, error) {
+	return m.transitRequest(ctx, targetId, userId, StatusActive)
+}
+
+func (m *MongoMapper) RejectRequest(ctx context.Context, targetId string, userId string) (bool, error) {
+	return m.transitRequest(ctx, targetId, userId, StatusRejected)
+}
+
+func (m *MongoMapper) WithdrawRequest(ctx context.Context, targetId string, userId string) (bool, error) {
+	return m.transitRequest(ctx, targetId, userId, StatusWithdrawn)
+}
+
+// transitRequest 把处于可流转状态的请求原子地改为to，返回值表示请求是否存在
+func (m *MongoMapper) transitRequest(ctx context.Context, targetId string, userId string, to Status) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": action.TargetType_USER, "user_id": userId, "status": bson.M{"$in": sourcesOf(to)}}
+
+	var old Follow
+
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &old, filter, bson.M{"$set": transitUpdate(to, time.Now())})
+
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return false, nil
+	case err == nil:
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// GetIncomingRequests 分页返回targetId收到的待处理请求及其总数
+func (m *MongoMapper) GetIncomingRequests(ctx context.Context, targetId string, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	filter := func() bson.M {
+		return bson.M{"target_id": targetId, "target_type": action.TargetType_USER, "status": StatusPending}
+	}
+	return m.getRequests(ctx, filter, opts)
+}
+
+// GetOutgoingRequests 分页返回userId发出的待处理请求及其总数
+func (m *MongoMapper) GetOutgoingRequests(ctx context.Context, userId string, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	filter := func() bson.M {
+		return bson.M{"user_id": userId, "target_type": action.TargetType_USER, "status": StatusPending}
+	}
+	return m.getRequests(ctx, filter, opts)
+}
+
+func (m *MongoMapper) getRequests(ctx context.Context, filter func() bson.M, opts *basic.PaginationOptions) ([]*Follow, int64, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	var follows []*Follow
+
+	query := filter()
+	err = m.conn.Find(ctx, &follows, query, p.MakeFindOptions(query))
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	follows, lastToken, err := pagination.Paginate(p, follows, cursorOf)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	total, err := m.conn.CountDocuments(ctx, filter())
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return follows, total, lastToken, nil
+}
@@ -0,0 +1,172 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// mongo自动创建的主键索引
+const primaryIndexName = "_id_"
+
+const ensureTimeout = 30 * time.Second
+
+var skipEnsure bool
+
+// Skip 之后创建的mapper不再创建索引，用于只读或修复数据的离线工具，避免在生产集合上建索引
+func Skip() {
+	skipEnsure = true
+}
+
+// Index 声明式的索引定义，Name必须唯一，用于和数据库中已有的索引比对
+type Index struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	Sparse bool // 只索引存在该字段的文档，用于可选字段上的唯一索引
+	// 大于0时为TTL索引，文档在该字段的时间之后ExpireAfter被自动删除，修改时需要先手动删除原索引
+	ExpireAfter time.Duration
+}
+
+func (i Index) model() mongo.IndexModel {
+	opts := options.Index().SetName(i.Name).SetUnique(i.Unique)
+	// 只在需要时设置，避免与已存在的非sparse索引定义不一致
+	if i.Sparse {
+		opts.SetSparse(true)
+	}
+	if i.ExpireAfter > 0 {
+		opts.SetExpireAfterSeconds(int32(i.ExpireAfter / time.Second))
+	}
+	return mongo.IndexModel{
+		Keys:    i.Keys,
+		Options: opts,
+	}
+}
+
+// existedIndex 数据库中已有的索引
+type existedIndex struct {
+	Name        string `bson:"name"`
+	Keys        bson.D `bson:"key"`
+	Unique      bool   `bson:"unique"`
+	Sparse      bool   `bson:"sparse"`
+	ExpireAfter int64  `bson:"expireAfterSeconds"`
+}
+
+// sameAs 键和选项都相同，只有名称可能不同
+func (e existedIndex) sameAs(i Index) bool {
+	return keySpec(e.Keys) == keySpec(i.Keys) && e.Unique == i.Unique && e.Sparse == i.Sparse && e.ExpireAfter == int64(i.ExpireAfter/time.Second)
+}
+
+// keySpec 把索引的键转换为可比较的字符串，数据库返回的方向可能是int32或double
+func keySpec(keys bson.D) string {
+	var spec string
+	for _, k := range keys {
+		spec += fmt.Sprintf("%s:%v,", k.Key, k.Value)
+	}
+	return spec
+}
+
+// Ensure 幂等地创建声明的索引，并打印数据库中存在但未声明的索引。
+// 早期版本未指定名称创建的索引与声明相同时直接复用，键相同但选项不同时删除后按声明重建。
+// 创建失败时panic，避免服务在缺少唯一索引的情况下启动，已有重复数据时需要先运行cmd/dedup
+func Ensure(conn *monc.Model, collection string, indexes []Index) {
+	if skipEnsure {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ensureTimeout)
+	defer cancel()
+
+	cursor, err := conn.Indexes().List(ctx)
+	if err != nil {
+		panic(fmt.Sprintf("获取%s索引失败: %v", collection, err))
+	}
+	var existed []existedIndex
+	if err = cursor.All(ctx, &existed); err != nil {
+		panic(fmt.Sprintf("获取%s索引失败: %v", collection, err))
+	}
+
+	byKeys := make(map[string]existedIndex, len(existed))
+	for _, e := range existed {
+		byKeys[keySpec(e.Keys)] = e
+	}
+
+	models := make([]mongo.IndexModel, 0, len(indexes))
+	declared := make(map[string]struct{}, len(indexes))
+	for _, i := range indexes {
+		e, ok := byKeys[keySpec(i.Keys)]
+		switch {
+		case !ok || e.Name == i.Name:
+			// 索引已存在且定义相同时CreateMany不会报错
+			models = append(models, i.model())
+			declared[i.Name] = struct{}{}
+		case e.sameAs(i):
+			// 重命名需要先删除，会留下没有唯一约束的窗口，因此保留原名称
+			log.Info("%s复用已存在的索引%s作为%s", collection, e.Name, i.Name)
+			declared[e.Name] = struct{}{}
+		default:
+			log.Info("%s删除定义不一致的索引%s，按声明重建为%s", collection, e.Name, i.Name)
+			if _, err = conn.Indexes().DropOne(ctx, e.Name); err != nil {
+				panic(fmt.Sprintf("删除%s索引%s失败: %v", collection, e.Name, err))
+			}
+			models = append(models, i.model())
+			declared[i.Name] = struct{}{}
+		}
+	}
+
+	if len(models) > 0 {
+		if _, err = conn.Indexes().CreateMany(ctx, models); err != nil {
+			panic(fmt.Sprintf("创建%s索引失败: %v", collection, err))
+		}
+	}
+
+	for _, e := range existed {
+		if _, ok := declared[e.Name]; !ok && e.Name != primaryIndexName {
+			log.Info("%s存在未声明的索引: %s", collection, e.Name)
+		}
+	}
+}
+
+// Dedup 删除违反唯一索引idx的重复文档，每组只保留update_at最新的一条，返回存在重复的组的键和删除的文档数，
+// dryRun为true时只统计不删除
+func Dedup(ctx context.Context, conn *monc.Model, idx Index, dryRun bool) ([]bson.M, int64, error) {
+	key := bson.M{}
+	for _, k := range idx.Keys {
+		key[k.Key] = "$" + k.Key
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "update_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": key, "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+
+	var groups []struct {
+		Key bson.M        `bson:"_id"`
+		IDs []interface{} `bson:"ids"`
+	}
+	if err := conn.Aggregate(ctx, &groups, pipeline, options.Aggregate().SetAllowDiskUse(true)); err != nil {
+		return nil, 0, err
+	}
+
+	keys := make([]bson.M, 0, len(groups))
+	var deleted int64
+	for _, group := range groups {
+		keys = append(keys, group.Key)
+		if dryRun {
+			deleted += int64(len(group.IDs) - 1)
+			continue
+		}
+		n, err := conn.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}})
+		if err != nil {
+			return nil, 0, err
+		}
+		deleted += n
+	}
+	return keys, deleted, nil
+}
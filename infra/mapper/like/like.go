@@ -12,6 +12,7 @@ type Like struct {
 	TargetType action.TargetType  `bson:"target_type" json:"target_type"`
 	UserId     string             `bson:"user_id,omitempty" json:"user_id"`
 	IsCancel   bool               `bson:"is_cancel" json:"is_cancel"`
+	Reaction   string             `bson:"reaction,omitempty" json:"reaction,omitempty"` // 为空表示默认表情，包括支持表情之前的点赞
 	CreateAt   time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
 	UpdateAt   time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
 	DeleteAt   time.Time          `bson:"delete_at,omitempty" json:"delete_at,omitempty"`
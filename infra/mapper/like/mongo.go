@@ -3,77 +3,120 @@ package like
 import (
 	"context"
 	"errors"
-	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
 	"github.com/zeromicro/go-zero/core/stores/monc"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/pagination"
+	"meowcloud-action/infra/mapper/upsert"
 	"time"
 )
 
 const prefixLikeCacheKey = "cache:like"
 const CollectionName = "like"
 
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// (target_id, target_type, user_id)唯一，保证并发upsert时只会留下一条记录
+	{Name: "target_user_unique", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "user_id", Value: 1}}, Unique: true},
+	// GetLikedUsers、CountLikes
+	{Name: "target_create_at", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetReactedUsers、CountReactedUsers
+	{Name: "target_reaction_create_at", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "reaction", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// GetUserLiked、CountLikesByUserId
+	{Name: "user_create_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "is_cancel", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// IterateByUserId、DeleteByUserId
+	{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: 1}}},
+}
+
 // 用于检查接口是否实现
 var _ IMongoMapper = (*MongoMapper)(nil)
 
 type IMongoMapper interface {
-	InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) error
+	InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
 	IsLiked(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
-	CancelLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) error
+	BatchIsLiked(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error)
+	CancelLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
 	CountLikes(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
-	GetLikedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) ([]*Like, int64, error)
-	GetUserLiked(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) ([]*Like, int64, error)
+	BatchCountLikes(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error)
+	GetLikedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) ([]*Like, string, error)
+	GetUserLiked(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) ([]*Like, int64, string, error)
 	CountLikesByUserId(ctx context.Context, targetType action.TargetType, userId string) (int64, error)
+	IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Like) error) error
+	DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Like, error)
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	React(ctx context.Context, targetId string, targetType action.TargetType, userId string, reaction string) (*Like, error)
+	GetReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string) (string, error)
+	CountReactions(ctx context.Context, targetId string, targetType action.TargetType) (map[string]int64, error)
+	GetReactedUsers(ctx context.Context, targetId string, targetType action.TargetType, reaction string, options *basic.PaginationOptions) ([]*Like, string, error)
+	CountReactedUsers(ctx context.Context, targetId string, targetType action.TargetType, reaction string) (int64, error)
 }
 
 type MongoMapper struct {
 	conn *monc.Model
+	// 没有reaction字段的点赞视为该表情
+	defaultReaction string
 }
 
 func NewMongoMapper() IMongoMapper {
 	aConfig := config.Get()
 	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
 	return &MongoMapper{
-		conn: conn,
+		conn:            conn,
+		defaultReaction: aConfig.Reaction.Default,
 	}
 }
 
-func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) error {
+func cursorOf(like *Like) pagination.Cursor {
+	return pagination.Cursor{ID: like.ID, CreateAt: like.CreateAt}
+}
+
+// InsertOne 原子地upsert一条like记录，返回值表示状态是否发生变化，已处于生效状态时返回false
+func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error) {
 
 	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
 
-	var like Like
+	now := time.Now()
+	// 重新点赞时按新的时间排序并回到默认表情，已经处于点赞状态时保持不变
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"create_at": keepIfActive("$create_at", now),
+			"reaction":  keepIfActive("$reaction", "$$REMOVE"),
+			"is_cancel": false,
+			"update_at": now,
+		}}},
+		{{Key: "$unset", Value: "delete_at"}},
+	}
+	var old Like
 
-	err := m.conn.FindOne(ctx, targetId+targetType.String()+userId, &like, filter)
+	err := upsert.FindOneAndUpsert(ctx, m.conn, &old, filter, update)
 
 	switch {
-	// 已经存在则修改isCancel状态
-	case err == nil:
-		log.Info("like已存在，改变isCancel")
-		like.IsCancel = false
-		_, err = m.conn.ReplaceOneNoCache(ctx, filter, like)
 	// 不存在则新建
 	case errors.Is(err, monc.ErrNotFound):
-		newLike := &Like{
-			ID:         primitive.NewObjectID(),
-			TargetId:   targetId,
-			TargetType: targetType,
-			UserId:     userId,
-			IsCancel:   false,
-			CreateAt:   time.Now(),
-			UpdateAt:   time.Now(),
-		}
-		key := prefixLikeCacheKey + newLike.TargetId + newLike.ID.Hex()
-		_, err = m.conn.InsertOne(ctx, key, newLike)
+		return true, nil
+	// 已经存在则只有原先被取消时才算生效
+	case err == nil:
+		return old.IsCancel, nil
+	default:
+		return false, err
 	}
-	return err
+}
 
+// keepIfActive 用于管道更新，原先处于点赞状态时保留field，否则（已取消或新建）取value，$set中的表达式都基于更新前的文档
+func keepIfActive(field string, value any) bson.M {
+	return bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$is_cancel", false}}, field, value}}
 }
 
+// IsLiked 返回userId是否处于点赞状态，早期版本返回的是IsCancel，语义相反
 func (m *MongoMapper) IsLiked(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error) {
 
 	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
@@ -85,28 +128,59 @@ func (m *MongoMapper) IsLiked(ctx context.Context, targetId string, targetType a
 	case errors.Is(err, monc.ErrNotFound):
 		return false, nil
 	case err == nil:
-		return like.IsCancel, nil
+		return !like.IsCancel, nil
 	default:
 		return false, err
 	}
 }
 
-func (m *MongoMapper) CancelLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) error {
+// BatchIsLiked 用一次$in查询返回userId对每个targetId的状态，不存在的targetId为false
+func (m *MongoMapper) BatchIsLiked(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error) {
 
-	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
+	result := make(map[string]bool, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = false
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
 
-	var like Like
+	filter := bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, "user_id": userId, "is_cancel": false}
 
-	err := m.conn.FindOneNoCache(ctx, &like, filter)
+	var likes []*Like
+
+	err := m.conn.Find(ctx, &likes, filter, options.Find().SetProjection(bson.M{"target_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range likes {
+		result[val.TargetId] = true
+	}
+
+	return result, nil
+}
+
+// CancelLike 原子地取消一条生效中的like记录，同时清除表情，返回值表示是否确实取消了记录
+func (m *MongoMapper) CancelLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId, "is_cancel": false}
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"is_cancel": true, "update_at": now, "delete_at": now}, "$unset": bson.M{"reaction": ""}}
+
+	var old Like
+
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &old, filter, update)
 
 	switch {
 	case errors.Is(err, monc.ErrNotFound):
-		return nil
+		return false, nil
 	case err == nil:
-		like.IsCancel = true
-		_, err = m.conn.ReplaceOne(ctx, "", filter, like)
+		return true, nil
+	default:
+		return false, err
 	}
-	return err
 }
 
 func (m *MongoMapper) CountLikes(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
@@ -123,59 +197,87 @@ func (m *MongoMapper) CountLikes(ctx context.Context, targetId string, targetTyp
 	return count, nil
 }
 
-func (m *MongoMapper) GetLikedUsers(ctx context.Context, targetId string, targetType action.TargetType, opts *basic.PaginationOptions) ([]*Like, int64, error) {
-	pageSize := *opts.Limit
-	skip := (*opts.Page - 1) * pageSize
+// BatchCountLikes 用一次聚合统计多个目标的数量，没有记录的targetId为0
+func (m *MongoMapper) BatchCountLikes(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error) {
 
-	likes := make([]*Like, pageSize)
+	result := make(map[string]int64, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = 0
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
 
-	filter := bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, "is_cancel": false}}},
+		{{Key: "$group", Value: bson.M{"_id": "$target_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	var counts []struct {
+		TargetId string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
 
-	err := m.conn.Find(ctx, &likes, filter, &options.FindOptions{
-		Limit: opts.Limit,
-		Skip:  &skip,
-		// 按时间降序，最新的在最前面
-		Sort: bson.M{"create_at": -1},
-	})
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	total, err := m.CountLikes(ctx, targetId, targetType)
+	for _, val := range counts {
+		result[val.TargetId] = val.Count
+	}
+
+	return result, nil
+}
+
+func (m *MongoMapper) GetLikedUsers(ctx context.Context, targetId string, targetType action.TargetType, opts *basic.PaginationOptions) ([]*Like, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var likes []*Like
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false}
+
+	err = m.conn.Find(ctx, &likes, filter, p.MakeFindOptions(filter))
 
 	if err != nil {
-		return nil, 0, err
+		return nil, "", err
 	}
-	return likes, total, err
+
+	return pagination.Paginate(p, likes, cursorOf)
 }
 
-func (m *MongoMapper) GetUserLiked(ctx context.Context, targetType action.TargetType, userId string, opts *basic.PaginationOptions) ([]*Like, int64, error) {
-	pageSize := *opts.Limit
-	skip := (*opts.Page - 1) * pageSize
+func (m *MongoMapper) GetUserLiked(ctx context.Context, targetType action.TargetType, userId string, opts *basic.PaginationOptions) ([]*Like, int64, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
 
-	likes := make([]*Like, pageSize)
+	var likes []*Like
 
 	filter := bson.M{"target_type": targetType, "user_id": userId, "is_cancel": false}
 
-	err := m.conn.Find(ctx, &likes, filter, &options.FindOptions{
-		Limit: opts.Limit,
-		Skip:  &skip,
-		// 按时间降序，最新的在最前面
-		Sort: bson.M{"create_at": -1},
-	})
+	err = m.conn.Find(ctx, &likes, filter, p.MakeFindOptions(filter))
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
+	}
+
+	likes, lastToken, err := pagination.Paginate(p, likes, cursorOf)
+	if err != nil {
+		return nil, 0, "", err
 	}
 
 	total, err := m.CountLikesByUserId(ctx, targetType, userId)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
-	return likes, total, err
+	return likes, total, lastToken, err
 }
 
 func (m *MongoMapper) CountLikesByUserId(ctx context.Context, targetType action.TargetType, userId string) (int64, error) {
@@ -191,3 +293,201 @@ func (m *MongoMapper) CountLikesByUserId(ctx context.Context, targetType action.
 
 	return count, nil
 }
+
+// DeleteByUserId 按_id顺序物理删除用户的至多limit条记录，返回被删除的记录，用于修正计数，返回空表示已经删除完毕。
+// 只按查到的_id删除，查询之后并发新建的记录会被保留，避免删除了没有修正计数的记录
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Like, error) {
+
+	filter := bson.M{"user_id": userId}
+
+	var likes []*Like
+
+	err := m.conn.Find(ctx, &likes, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(likes) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(likes))
+	for _, val := range likes {
+		ids = append(ids, val.ID)
+	}
+
+	_, err = m.conn.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return likes, nil
+}
+
+// CountByTarget 统计指向目标的全部记录，包括已取消的
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// DeleteByTarget 物理删除指向目标的全部记录，返回删除的数量
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
+
+// IterateByUserId 按_id顺序逐条遍历用户afterId之后的最多limit条记录，包括已取消的，afterId为零值时从头开始，fn返回错误时停止遍历
+func (m *MongoMapper) IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Like) error) error {
+
+	filter := bson.M{"user_id": userId}
+	if !afterId.IsZero() {
+		filter["_id"] = bson.M{"$gt": afterId}
+	}
+
+	cursor, err := m.conn.Collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(limit))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var like Like
+		if err = cursor.Decode(&like); err != nil {
+			return err
+		}
+		if err = fn(&like); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// reactionFilter 默认表情同时匹配没有reaction字段的点赞
+func (m *MongoMapper) reactionFilter(reaction string) any {
+	if reaction == m.defaultReaction {
+		return bson.M{"$in": bson.A{reaction, nil}}
+	}
+	return reaction
+}
+
+// React 原子地upsert一条带表情的like记录，已有表情时替换，返回修改前的记录，原先不存在时返回nil
+func (m *MongoMapper) React(ctx context.Context, targetId string, targetType action.TargetType, userId string, reaction string) (*Like, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId}
+
+	now := time.Now()
+	// 从取消状态恢复时按新的时间排序，只换表情时保持原来的位置
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"create_at": keepIfActive("$create_at", now),
+			"reaction":  reaction,
+			"is_cancel": false,
+			"update_at": now,
+		}}},
+		{{Key: "$unset", Value: "delete_at"}},
+	}
+	var old Like
+
+	err := upsert.FindOneAndUpsert(ctx, m.conn, &old, filter, update)
+
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		if old.Reaction == "" {
+			old.Reaction = m.defaultReaction
+		}
+		return &old, nil
+	default:
+		return nil, err
+	}
+}
+
+// GetReaction 返回用户对目标当前的表情，未点赞时为空
+func (m *MongoMapper) GetReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string) (string, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "user_id": userId, "is_cancel": false}
+
+	var like Like
+
+	err := m.conn.FindOneNoCache(ctx, &like, filter)
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return "", nil
+	case err == nil:
+		if like.Reaction == "" {
+			return m.defaultReaction, nil
+		}
+		return like.Reaction, nil
+	default:
+		return "", err
+	}
+}
+
+// CountReactions 按表情统计目标生效中的点赞数，没有表情的点赞计入默认表情
+func (m *MongoMapper) CountReactions(ctx context.Context, targetId string, targetType action.TargetType) (map[string]int64, error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"$ifNull": bson.A{"$reaction", m.defaultReaction}}, "count": bson.M{"$sum": 1}}}},
+	}
+
+	var counts []struct {
+		Reaction string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(counts))
+	for _, val := range counts {
+		result[val.Reaction] += val.Count
+	}
+
+	return result, nil
+}
+
+func (m *MongoMapper) GetReactedUsers(ctx context.Context, targetId string, targetType action.TargetType, reaction string, opts *basic.PaginationOptions) ([]*Like, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var likes []*Like
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false, "reaction": m.reactionFilter(reaction)}
+
+	err = m.conn.Find(ctx, &likes, filter, p.MakeFindOptions(filter))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	likes, lastToken, err := pagination.Paginate(p, likes, cursorOf)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, val := range likes {
+		if val.Reaction == "" {
+			val.Reaction = m.defaultReaction
+		}
+	}
+
+	return likes, lastToken, nil
+}
+
+func (m *MongoMapper) CountReactedUsers(ctx context.Context, targetId string, targetType action.TargetType, reaction string) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType, "is_cancel": false, "reaction": m.reactionFilter(reaction)}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
@@ -0,0 +1,179 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/index"
+	"time"
+)
+
+const CollectionName = "outbox"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// ClaimPending
+	{Name: "status_lock_until", Keys: bson.D{{Key: "status", Value: 1}, {Key: "lock_until", Value: 1}, {Key: "_id", Value: 1}}},
+	// DeleteByTarget
+	{Name: "event_target", Keys: bson.D{{Key: "event.target_id", Value: 1}, {Key: "event.target_type", Value: 1}}},
+	// DeleteByUserId
+	{Name: "event_user_id", Keys: bson.D{{Key: "event.user_id", Value: 1}}},
+}
+
+// deliveredIndex 已投递的消息保留retention后由mongo自动删除，待投递和死信没有deliver_at，不受影响
+func deliveredIndex(retention time.Duration) index.Index {
+	return index.Index{Name: "deliver_at_ttl", Keys: bson.D{{Key: "deliver_at", Value: 1}}, ExpireAfter: retention}
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	InsertOne(ctx context.Context, act event.Action, op event.Op, targetId string, targetType action.TargetType, userId string) error
+	ClaimPending(ctx context.Context, lease time.Duration) (*Message, error)
+	MarkDelivered(ctx context.Context, id primitive.ObjectID) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID, cause error) error
+	MarkSinkDelivered(ctx context.Context, id primitive.ObjectID, sink string) error
+	MarkDead(ctx context.Context, id primitive.ObjectID, cause error) error
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, append([]index.Index{deliveredIndex(aConfig.Outbox.Retention)}, Indexes...))
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+// Transaction 在事务中执行fn，fn中使用传入的ctx访问任意集合都会加入该事务
+func (m *MongoMapper) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	sess, err := m.conn.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+func (m *MongoMapper) InsertOne(ctx context.Context, act event.Action, op event.Op, targetId string, targetType action.TargetType, userId string) error {
+
+	now := time.Now()
+	newMessage := &Message{
+		ID: primitive.NewObjectID(),
+		Event: event.Event{
+			ID:         primitive.NewObjectID(),
+			Action:     act,
+			Op:         op,
+			TargetId:   targetId,
+			TargetType: targetType,
+			UserId:     userId,
+			CreateAt:   now,
+		},
+		Status:   Pending,
+		CreateAt: now,
+	}
+
+	_, err := m.conn.InsertOneNoCache(ctx, newMessage)
+	return err
+}
+
+// ClaimPending 按写入顺序领取一条待投递的消息并加锁lease时长，没有可领取的消息时返回nil
+func (m *MongoMapper) ClaimPending(ctx context.Context, lease time.Duration) (*Message, error) {
+
+	now := time.Now()
+	filter := bson.M{"status": Pending, "lock_until": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"lock_until": now.Add(lease)}, "$inc": bson.M{"attempts": 1}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"_id": 1}).SetReturnDocument(options.After)
+
+	var message Message
+
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &message, filter, update, opts)
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &message, nil
+	default:
+		return nil, err
+	}
+}
+
+func (m *MongoMapper) MarkDelivered(ctx context.Context, id primitive.ObjectID) error {
+
+	update := bson.M{"$set": bson.M{"status": Delivered, "deliver_at": time.Now()}}
+
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, update)
+	return err
+}
+
+// MarkFailed 记录失败原因，消息保持待投递状态，锁过期后会被再次领取
+func (m *MongoMapper) MarkFailed(ctx context.Context, id primitive.ObjectID, cause error) error {
+
+	update := bson.M{"$set": bson.M{"last_error": cause.Error()}}
+
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, update)
+	return err
+}
+
+// MarkSinkDelivered 记录消息已成功投递到名为sink的下游
+func (m *MongoMapper) MarkSinkDelivered(ctx context.Context, id primitive.ObjectID, sink string) error {
+
+	update := bson.M{"$addToSet": bson.M{"delivered_to": sink}}
+
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, update)
+	return err
+}
+
+// MarkDead 把消息转为死信，ClaimPending不再领取
+func (m *MongoMapper) MarkDead(ctx context.Context, id primitive.ObjectID, cause error) error {
+
+	update := bson.M{"$set": bson.M{"status": Dead, "last_error": cause.Error()}}
+
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, update)
+	return err
+}
+
+// CountByTarget 统计目标尚未投递或已转入死信的消息，已投递的消息会由TTL索引清理
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"event.target_id": targetId, "event.target_type": targetType, "status": bson.M{"$ne": Delivered}}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// DeleteByTarget 物理删除目标尚未投递或已转入死信的消息，返回删除的数量
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"event.target_id": targetId, "event.target_type": targetType, "status": bson.M{"$ne": Delivered}}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
+
+// DeleteByUserId 物理删除用户产生的以及指向该用户的全部消息，包括保留期内已投递的，返回删除的数量
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"event.user_id": userId},
+		bson.M{"event.target_id": userId, "event.target_type": action.TargetType_USER},
+	}}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"meowcloud-action/infra/mapper/event"
+	"time"
+)
+
+// Status 消息的投递状态
+type Status string
+
+const (
+	Pending   Status = "pending"
+	Delivered Status = "delivered"
+	// Dead 超过最大投递次数的死信，不再自动投递，需要人工排查后处理
+	Dead Status = "dead"
+)
+
+// Message 与行为状态变更在同一事务中写入，由relay投递到消息队列
+type Message struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Event     event.Event        `bson:"event" json:"event"`
+	Status    Status             `bson:"status" json:"status"`
+	Attempts  int64              `bson:"attempts" json:"attempts"`
+	LastError string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	LockUntil time.Time          `bson:"lock_until" json:"lock_until"`
+	CreateAt  time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
+	DeliverAt time.Time          `bson:"deliver_at,omitempty" json:"deliver_at,omitempty"`
+	// DeliveredTo 已成功投递的下游，重试时跳过
+	DeliveredTo []string `bson:"delivered_to,omitempty" json:"delivered_to,omitempty"`
+}
+
+// IsDeliveredTo 消息是否已成功投递到名为sink的下游
+func (m *Message) IsDeliveredTo(sink string) bool {
+	for _, name := range m.DeliveredTo {
+		if name == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// Topic 消息的主题，如like.do、follow.cancel
+func (m *Message) Topic() string {
+	return string(m.Event.Action) + "." + string(m.Event.Op)
+}
@@ -0,0 +1,178 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const defaultPageSize = int64(10)
+
+var ErrInvalidToken = errors.New("分页token无效")
+
+// Cursor 由(create_at, _id)组成的游标，create_at相同时用_id保证顺序稳定
+type Cursor struct {
+	ID       primitive.ObjectID `json:"id"`
+	CreateAt time.Time          `json:"create_at"`
+}
+
+// token 同时记录当前页首尾两条记录，向前翻页使用Front，向后翻页使用Back，两个方向都可以继续翻页。
+// More表示沿生成token的方向继续翻页是否还有数据
+type token struct {
+	Front Cursor `json:"front"`
+	Back  Cursor `json:"back"`
+	More  bool   `json:"more"`
+}
+
+func encodeToken(t token) (string, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeToken(s string) (token, error) {
+	var t token
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, ErrInvalidToken
+	}
+	if err = json.Unmarshal(raw, &t); err != nil {
+		return t, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// Paginator 有LastToken时按游标分页，否则退化为page/offset分页，兼容原有调用方。不会修改传入的PaginationOptions
+type Paginator struct {
+	limit    int64
+	skip     int64
+	backward bool
+	cursor   *Cursor
+}
+
+func NewPaginator(opts *basic.PaginationOptions) (*Paginator, error) {
+	p := &Paginator{limit: defaultPageSize}
+	if opts == nil {
+		return p, nil
+	}
+	if opts.Limit != nil && *opts.Limit > 0 {
+		p.limit = *opts.Limit
+	}
+
+	if opts.LastToken == nil || *opts.LastToken == "" {
+		switch {
+		case opts.Page != nil && *opts.Page > 0:
+			p.skip = (*opts.Page - 1) * p.limit
+		case opts.Offset != nil:
+			p.skip = *opts.Offset
+		}
+		return p, nil
+	}
+
+	t, err := decodeToken(*opts.LastToken)
+	if err != nil {
+		return nil, err
+	}
+	p.backward = opts.Backward != nil && *opts.Backward
+	if p.backward {
+		p.cursor = &t.Front
+	} else {
+		p.cursor = &t.Back
+	}
+	return p, nil
+}
+
+func (p *Paginator) Backward() bool {
+	return p.backward
+}
+
+// MakeFindOptions 生成查询选项，多查询一条用于判断是否还有下一页，游标模式下会在原地为filter追加游标条件
+func (p *Paginator) MakeFindOptions(filter bson.M) *options.FindOptions {
+	opts := options.Find().SetLimit(p.limit + 1)
+
+	if p.cursor == nil {
+		// 按时间降序，最新的在最前面
+		return opts.SetSkip(p.skip).SetSort(bson.D{{Key: "create_at", Value: -1}, {Key: "_id", Value: -1}})
+	}
+
+	op, order := "$lt", -1
+	if p.backward {
+		op, order = "$gt", 1
+	}
+	filter["$or"] = bson.A{
+		bson.M{"create_at": bson.M{op: p.cursor.CreateAt}},
+		bson.M{"create_at": p.cursor.CreateAt, "_id": bson.M{op: p.cursor.ID}},
+	}
+	return opts.SetSort(bson.D{{Key: "create_at", Value: order}, {Key: "_id", Value: order}})
+}
+
+// MakePipeline 生成与MakeFindOptions顺序一致的聚合管道，stages在排序之后、分页之前执行，用于需要关联过滤后再分页的查询
+func (p *Paginator) MakePipeline(filter bson.M, stages ...bson.D) mongo.Pipeline {
+	opts := p.MakeFindOptions(filter)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: opts.Sort}},
+	}
+	pipeline = append(pipeline, stages...)
+	if opts.Skip != nil && *opts.Skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: *opts.Skip}})
+	}
+	return append(pipeline, bson.D{{Key: "$limit", Value: *opts.Limit}})
+}
+
+// Paginate 去掉多查询的一条并把结果整理为时间降序，返回请求相邻页时作为LastToken传入的token。
+// 只要当前页有数据就会返回token，调用方到达一端后仍可以用它反向翻页，是否还有数据由HasMore判断
+func Paginate[T any](p *Paginator, data []*T, cursorOf func(*T) Cursor) ([]*T, string, error) {
+	more := int64(len(data)) > p.limit
+	if more {
+		data = data[:p.limit]
+	}
+
+	// 向前翻页时按升序查询，需要翻转回降序
+	if p.backward {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	if len(data) == 0 {
+		return data, "", nil
+	}
+
+	lastToken, err := encodeToken(token{Front: cursorOf(data[0]), Back: cursorOf(data[len(data)-1]), More: more})
+	if err != nil {
+		return nil, "", err
+	}
+	return data, lastToken, nil
+}
+
+// HasMore 返回沿生成lastToken的方向继续翻页是否还有数据，lastToken为空表示当前页没有数据
+func HasMore(lastToken string) bool {
+	if lastToken == "" {
+		return false
+	}
+	t, err := decodeToken(lastToken)
+	return err == nil && t.More
+}
+
+// PageOnly 返回去掉LastToken的副本，用于响应中没有token字段的IDL接口，这些接口只支持page/offset分页
+func PageOnly(opts *basic.PaginationOptions) *basic.PaginationOptions {
+	if opts == nil || opts.LastToken == nil {
+		return opts
+	}
+	return &basic.PaginationOptions{
+		Page:     opts.Page,
+		Limit:    opts.Limit,
+		Backward: opts.Backward,
+		Offset:   opts.Offset,
+	}
+}
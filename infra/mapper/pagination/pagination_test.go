@@ -0,0 +1,200 @@
+package pagination
+
+import (
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+type item struct {
+	ID       primitive.ObjectID
+	CreateAt time.Time
+}
+
+func itemCursor(v *item) Cursor {
+	return Cursor{ID: v.ID, CreateAt: v.CreateAt}
+}
+
+// newItems 返回n条按时间降序排列的记录
+func newItems(n int) []*item {
+	now := time.Now().Truncate(time.Millisecond)
+	items := make([]*item, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, &item{ID: primitive.NewObjectID(), CreateAt: now.Add(-time.Duration(i) * time.Second)})
+	}
+	return items
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	want := token{
+		Front: Cursor{ID: primitive.NewObjectID(), CreateAt: now},
+		Back:  Cursor{ID: primitive.NewObjectID(), CreateAt: now.Add(-time.Hour)},
+	}
+
+	s, err := encodeToken(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decodeToken(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Front.ID != want.Front.ID || !got.Front.CreateAt.Equal(want.Front.CreateAt) {
+		t.Errorf("Front = %+v, want %+v", got.Front, want.Front)
+	}
+	if got.Back.ID != want.Back.ID || !got.Back.CreateAt.Equal(want.Back.CreateAt) {
+		t.Errorf("Back = %+v, want %+v", got.Back, want.Back)
+	}
+}
+
+func TestDecodeInvalidToken(t *testing.T) {
+	for _, s := range []string{"!!!", "bm90IGpzb24"} {
+		if _, err := decodeToken(s); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("decodeToken(%q) err = %v, want ErrInvalidToken", s, err)
+		}
+		if HasMore(s) {
+			t.Errorf("HasMore(%q) = true, want false", s)
+		}
+	}
+
+	lastToken := "!!!"
+	if _, err := NewPaginator(&basic.PaginationOptions{LastToken: &lastToken}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("NewPaginator() err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestPaginateEmptyPage(t *testing.T) {
+	p, err := NewPaginator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, lastToken, err := Paginate(p, []*item{}, itemCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 || lastToken != "" || HasMore(lastToken) {
+		t.Errorf("Paginate() = %d条, token %q, want 0条和空token", len(data), lastToken)
+	}
+}
+
+func TestPaginateLastPage(t *testing.T) {
+	limit := int64(3)
+	p, _ := NewPaginator(&basic.PaginationOptions{Limit: &limit})
+
+	items := newItems(3)
+	data, lastToken, err := Paginate(p, items, itemCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 || HasMore(lastToken) {
+		t.Errorf("Paginate() = %d条, HasMore %v, want 3条且没有更多数据", len(data), HasMore(lastToken))
+	}
+
+	// 到达最后一页后仍可以用token反向翻页，游标为当前页第一条
+	backward := true
+	p, err = NewPaginator(&basic.PaginationOptions{Limit: &limit, LastToken: &lastToken, Backward: &backward})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cursor == nil || p.cursor.ID != items[0].ID {
+		t.Errorf("cursor = %+v, want %v", p.cursor, items[0].ID)
+	}
+}
+
+func TestPaginateMore(t *testing.T) {
+	limit := int64(3)
+	p, _ := NewPaginator(&basic.PaginationOptions{Limit: &limit})
+
+	// 多查询的一条表示还有下一页
+	items := newItems(4)
+	data, lastToken, err := Paginate(p, items, itemCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 || !HasMore(lastToken) {
+		t.Fatalf("Paginate() = %d条, HasMore %v, want 3条且还有更多数据", len(data), HasMore(lastToken))
+	}
+
+	next, err := decodeToken(lastToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Front.ID != items[0].ID || next.Back.ID != items[2].ID {
+		t.Errorf("token = %+v, want front %v back %v", next, items[0].ID, items[2].ID)
+	}
+
+	// 用返回的token向后翻页，游标为当前页最后一条
+	p, err = NewPaginator(&basic.PaginationOptions{Limit: &limit, LastToken: &lastToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := bson.M{}
+	opts := p.MakeFindOptions(filter)
+	if _, ok := filter["$or"]; !ok {
+		t.Error("游标分页应在filter中追加游标条件")
+	}
+	if opts.Skip != nil {
+		t.Errorf("游标分页不应使用skip，got %d", *opts.Skip)
+	}
+	if *opts.Limit != limit+1 {
+		t.Errorf("Limit = %d, want %d", *opts.Limit, limit+1)
+	}
+}
+
+func TestPaginateBackward(t *testing.T) {
+	limit := int64(2)
+	lastToken, _ := encodeToken(token{})
+	backward := true
+	p, err := NewPaginator(&basic.PaginationOptions{Limit: &limit, LastToken: &lastToken, Backward: &backward})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 向前翻页时按升序查询，结果需要翻转回降序
+	items := newItems(3)
+	ascending := []*item{items[2], items[1], items[0]}
+	data, _, err := Paginate(p, ascending, itemCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || data[0].ID != items[1].ID || data[1].ID != items[2].ID {
+		t.Errorf("Paginate() 没有按时间降序返回")
+	}
+}
+
+func TestPageAndOffset(t *testing.T) {
+	limit, page, offset := int64(10), int64(3), int64(7)
+
+	p, _ := NewPaginator(&basic.PaginationOptions{Limit: &limit, Page: &page})
+	if opts := p.MakeFindOptions(bson.M{}); *opts.Skip != 20 {
+		t.Errorf("page skip = %d, want 20", *opts.Skip)
+	}
+
+	p, _ = NewPaginator(&basic.PaginationOptions{Limit: &limit, Offset: &offset})
+	if opts := p.MakeFindOptions(bson.M{}); *opts.Skip != 7 {
+		t.Errorf("offset skip = %d, want 7", *opts.Skip)
+	}
+}
+
+func TestPageOnly(t *testing.T) {
+	lastToken := "token"
+	page := int64(2)
+	opts := &basic.PaginationOptions{LastToken: &lastToken, Page: &page}
+
+	got := PageOnly(opts)
+	if got.LastToken != nil || got.Page == nil || *got.Page != 2 {
+		t.Errorf("PageOnly() = %+v", got)
+	}
+	if opts.LastToken == nil {
+		t.Error("PageOnly不应修改传入的参数")
+	}
+	if PageOnly(nil) != nil {
+		t.Error("PageOnly(nil) 应返回nil")
+	}
+}
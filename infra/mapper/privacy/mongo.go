@@ -0,0 +1,68 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"time"
+)
+
+const CollectionName = "privacy"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	{Name: "user_unique", Keys: bson.D{{Key: "user_id", Value: 1}}, Unique: true},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	SetPrivate(ctx context.Context, userId string, private bool) error
+	IsPrivate(ctx context.Context, userId string) (bool, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func (m *MongoMapper) SetPrivate(ctx context.Context, userId string, private bool) error {
+	filter := bson.M{"user_id": userId}
+	update := bson.M{"$set": bson.M{"private": private, "update_at": time.Now()}}
+
+	_, err := m.conn.UpdateOneNoCache(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (m *MongoMapper) IsPrivate(ctx context.Context, userId string) (bool, error) {
+	var privacy Privacy
+
+	err := m.conn.FindOneNoCache(ctx, &privacy, bson.M{"user_id": userId})
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return false, nil
+	case err == nil:
+		return privacy.Private, nil
+	default:
+		return false, err
+	}
+}
+
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	return m.conn.DeleteMany(ctx, bson.M{"user_id": userId})
+}
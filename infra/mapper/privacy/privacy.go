@@ -0,0 +1,14 @@
+package privacy
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Privacy 用户的隐私设置，没有记录的用户视为公开
+type Privacy struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserId   string             `bson:"user_id" json:"user_id"`
+	Private  bool               `bson:"private" json:"private"` // 私密账号的关注需要经过本人同意
+	UpdateAt time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
+}
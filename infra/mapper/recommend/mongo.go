@@ -0,0 +1,98 @@
+package recommend
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"time"
+)
+
+const CollectionName = "recommendation"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	{Name: "user_target_type_unique", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_type", Value: 1}}, Unique: true},
+	// PullCandidate
+	{Name: "target_type_candidate", Keys: bson.D{{Key: "target_type", Value: 1}, {Key: "candidates._id", Value: 1}}},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	Upsert(ctx context.Context, userId string, targetType action.TargetType, candidates []*Candidate) error
+	FindOne(ctx context.Context, userId string, targetType action.TargetType) (*Recommendation, error)
+	DeleteByUserId(ctx context.Context, userId string) (int64, error)
+	CountByCandidate(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	PullCandidate(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func (m *MongoMapper) Upsert(ctx context.Context, userId string, targetType action.TargetType, candidates []*Candidate) error {
+	if candidates == nil {
+		candidates = []*Candidate{}
+	}
+
+	filter := bson.M{"user_id": userId, "target_type": targetType}
+	update := bson.M{"$set": bson.M{"candidates": candidates, "update_at": time.Now()}}
+
+	_, err := m.conn.UpdateOneNoCache(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// FindOne 没有预计算结果时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, userId string, targetType action.TargetType) (*Recommendation, error) {
+	filter := bson.M{"user_id": userId, "target_type": targetType}
+
+	var recommendation Recommendation
+
+	err := m.conn.FindOneNoCache(ctx, &recommendation, filter)
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &recommendation, nil
+	default:
+		return nil, err
+	}
+}
+
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string) (int64, error) {
+	return m.conn.DeleteMany(ctx, bson.M{"user_id": userId})
+}
+
+// CountByCandidate 统计推荐了目标的预计算结果
+func (m *MongoMapper) CountByCandidate(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	return m.conn.CountDocuments(ctx, bson.M{"target_type": targetType, "candidates._id": targetId})
+}
+
+// PullCandidate 从全部预计算结果中移除目标，返回修改的结果数
+func (m *MongoMapper) PullCandidate(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+
+	filter := bson.M{"target_type": targetType, "candidates._id": targetId}
+	update := bson.M{"$pull": bson.M{"candidates": bson.M{"_id": targetId}}}
+
+	result, err := m.conn.UpdateManyNoCache(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
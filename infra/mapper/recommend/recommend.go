@@ -0,0 +1,22 @@
+package recommend
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Candidate 一个推荐对象，Score为用户关注的人中关注了该对象的人数
+type Candidate struct {
+	TargetId string `bson:"_id" json:"target_id"`
+	Score    int64  `bson:"score" json:"score"`
+}
+
+// Recommendation 离线预计算的推荐结果，每个(user_id, target_type)一条
+type Recommendation struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserId     string             `bson:"user_id" json:"user_id"`
+	TargetType action.TargetType  `bson:"target_type" json:"target_type"`
+	Candidates []*Candidate       `bson:"candidates" json:"candidates"`
+	UpdateAt   time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
+}
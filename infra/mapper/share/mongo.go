@@ -8,24 +8,54 @@ import (
 	"github.com/zeromicro/go-zero/core/stores/monc"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"meowcloud-action/infra/mapper/pagination"
 	"time"
 )
 
 const prefixShareCacheKey = "cache:share"
 const CollectionName = "share"
 
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// IsShared
+	{Name: "target_user", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "user_id", Value: 1}}},
+	// GetSharedUsers、CountShares
+	{Name: "target_create_at", Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// FindByCode，历史记录没有code
+	{Name: "code_unique", Keys: bson.D{{Key: "code", Value: 1}}, Unique: true, Sparse: true},
+	// GetUserShared、CountSharesByUserId
+	{Name: "user_create_at", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "create_at", Value: -1}, {Key: "_id", Value: -1}}},
+	// IterateByUserId、DeleteByUserId
+	{Name: "user_id", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: 1}}},
+}
+
 // 用于检查接口是否实现
 var _ IMongoMapper = (*MongoMapper)(nil)
 
 type IMongoMapper interface {
-	InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) error
+	InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string, channel string, metadata map[string]string) (*Share, error)
 	IsShared(ctx context.Context, targetId string, targetType action.TargetType, userId string) (bool, error)
+	BatchIsShared(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error)
 	CountShares(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
-	GetSharedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) ([]*Share, int64, error)
-	GetUserShared(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) ([]*Share, int64, error)
+	BatchCountShares(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error)
+	GetSharedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) ([]*Share, string, error)
+	GetUserShared(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) ([]*Share, int64, string, error)
 	CountSharesByUserId(ctx context.Context, targetType action.TargetType, userId string) (int64, error)
+	IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Share) error) error
+	DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Share, error)
+	CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	GetIdsByTarget(ctx context.Context, targetId string, targetType action.TargetType) ([]string, error)
+	CountSharesByChannel(ctx context.Context, targetId string, targetType action.TargetType) (map[string]int64, error)
+	FindByCode(ctx context.Context, code string) (*Share, error)
+	IncrStats(ctx context.Context, id primitive.ObjectID, field string) error
+	GetUserStats(ctx context.Context, userId string) (*UserStats, error)
+	CountSharers(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+	BatchCountSharers(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error)
 }
 
 type MongoMapper struct {
@@ -35,27 +65,40 @@ type MongoMapper struct {
 func NewMongoMapper() IMongoMapper {
 	aConfig := config.Get()
 	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
 	return &MongoMapper{
 		conn: conn,
 	}
 }
 
-func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string) error {
+func cursorOf(share *Share) pagination.Cursor {
+	return pagination.Cursor{ID: share.ID, CreateAt: share.CreateAt}
+}
+
+func (m *MongoMapper) InsertOne(ctx context.Context, targetId string, targetType action.TargetType, userId string, channel string, metadata map[string]string) (*Share, error) {
 
 	newShare := &Share{
 		ID:         primitive.NewObjectID(),
 		TargetId:   targetId,
 		TargetType: targetType,
 		UserId:     userId,
+		Channel:    channel,
+		Metadata:   metadata,
 		CreateAt:   time.Now(),
 		UpdateAt:   time.Now(),
 	}
 	key := prefixShareCacheKey + newShare.TargetId + newShare.ID.Hex()
-	_, err := m.conn.InsertOne(ctx, key, newShare)
-	if err != nil {
-		return err
+
+	// code冲突的概率极低，冲突时返回唯一索引冲突，由withOutbox重试整个事务重新生成
+	var err error
+	if newShare.Code, err = newCode(); err != nil {
+		return nil, err
 	}
-	return nil
+	if _, err = m.conn.InsertOne(ctx, key, newShare); err != nil {
+		return nil, err
+	}
+	return newShare, nil
 
 }
 
@@ -74,6 +117,34 @@ func (m *MongoMapper) IsShared(ctx context.Context, targetId string, targetType
 	}
 }
 
+// BatchIsShared 用一次$in查询返回userId对每个targetId的状态，不存在的targetId为false
+func (m *MongoMapper) BatchIsShared(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (map[string]bool, error) {
+
+	result := make(map[string]bool, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = false
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	filter := bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType, "user_id": userId}
+
+	var shares []*Share
+
+	err := m.conn.Find(ctx, &shares, filter, options.Find().SetProjection(bson.M{"target_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range shares {
+		result[val.TargetId] = true
+	}
+
+	return result, nil
+}
+
 func (m *MongoMapper) CountShares(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
 	filter := bson.M{"target_id": targetId, "target_type": targetType}
 
@@ -88,59 +159,87 @@ func (m *MongoMapper) CountShares(ctx context.Context, targetId string, targetTy
 	return count, nil
 }
 
-func (m *MongoMapper) GetSharedUsers(ctx context.Context, targetId string, targetType action.TargetType, opts *basic.PaginationOptions) ([]*Share, int64, error) {
-	pageSize := *opts.Limit
-	skip := (*opts.Page - 1) * pageSize
+// BatchCountShares 用一次聚合统计多个目标的数量，没有记录的targetId为0
+func (m *MongoMapper) BatchCountShares(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error) {
+
+	result := make(map[string]int64, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = 0
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
 
-	shares := make([]*Share, pageSize)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType}}},
+		{{Key: "$group", Value: bson.M{"_id": "$target_id", "count": bson.M{"$sum": 1}}}},
+	}
 
-	filter := bson.M{"target_id": targetId, "target_type": targetType}
+	var counts []struct {
+		TargetId string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
 
-	err := m.conn.Find(ctx, &shares, filter, &options.FindOptions{
-		Limit: opts.Limit,
-		Skip:  &skip,
-		// 按时间降序，最新的在最前面
-		Sort: bson.M{"create_at": -1},
-	})
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	total, err := m.CountShares(ctx, targetId, targetType)
+	for _, val := range counts {
+		result[val.TargetId] = val.Count
+	}
+
+	return result, nil
+}
+
+func (m *MongoMapper) GetSharedUsers(ctx context.Context, targetId string, targetType action.TargetType, opts *basic.PaginationOptions) ([]*Share, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var shares []*Share
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	err = m.conn.Find(ctx, &shares, filter, p.MakeFindOptions(filter))
 
 	if err != nil {
-		return nil, 0, err
+		return nil, "", err
 	}
-	return shares, total, err
+
+	return pagination.Paginate(p, shares, cursorOf)
 }
 
-func (m *MongoMapper) GetUserShared(ctx context.Context, targetType action.TargetType, userId string, opts *basic.PaginationOptions) ([]*Share, int64, error) {
-	pageSize := *opts.Limit
-	skip := (*opts.Page - 1) * pageSize
+func (m *MongoMapper) GetUserShared(ctx context.Context, targetType action.TargetType, userId string, opts *basic.PaginationOptions) ([]*Share, int64, string, error) {
+	p, err := pagination.NewPaginator(opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
 
-	shares := make([]*Share, pageSize)
+	var shares []*Share
 
 	filter := bson.M{"target_type": targetType, "user_id": userId}
 
-	err := m.conn.Find(ctx, &shares, filter, &options.FindOptions{
-		Limit: opts.Limit,
-		Skip:  &skip,
-		// 按时间降序，最新的在最前面
-		Sort: bson.M{"create_at": -1},
-	})
+	err = m.conn.Find(ctx, &shares, filter, p.MakeFindOptions(filter))
+
+	if err != nil {
+		return nil, 0, "", err
+	}
 
+	shares, lastToken, err := pagination.Paginate(p, shares, cursorOf)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
 	total, err := m.CountSharesByUserId(ctx, targetType, userId)
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
-	return shares, total, err
+	return shares, total, lastToken, err
 }
 
 func (m *MongoMapper) CountSharesByUserId(ctx context.Context, targetType action.TargetType, userId string) (int64, error) {
@@ -156,3 +255,224 @@ func (m *MongoMapper) CountSharesByUserId(ctx context.Context, targetType action
 
 	return count, nil
 }
+
+// DeleteByUserId 按_id顺序物理删除用户的至多limit条记录，返回被删除的记录，用于修正计数，返回空表示已经删除完毕。
+// 只按查到的_id删除，查询之后并发新建的记录会被保留，避免删除了没有修正计数的记录
+func (m *MongoMapper) DeleteByUserId(ctx context.Context, userId string, limit int64) ([]*Share, error) {
+
+	filter := bson.M{"user_id": userId}
+
+	var shares []*Share
+
+	err := m.conn.Find(ctx, &shares, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(shares) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(shares))
+	for _, val := range shares {
+		ids = append(ids, val.ID)
+	}
+
+	_, err = m.conn.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// CountByTarget 统计指向目标的全部记录，包括已取消的
+func (m *MongoMapper) CountByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.CountDocuments(ctx, filter)
+}
+
+// DeleteByTarget 物理删除指向目标的全部记录，返回删除的数量
+func (m *MongoMapper) DeleteByTarget(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	return m.conn.DeleteMany(ctx, filter)
+}
+
+// IterateByUserId 按_id顺序逐条遍历用户afterId之后的最多limit条记录，包括已取消的，afterId为零值时从头开始，fn返回错误时停止遍历
+func (m *MongoMapper) IterateByUserId(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, fn func(*Share) error) error {
+
+	filter := bson.M{"user_id": userId}
+	if !afterId.IsZero() {
+		filter["_id"] = bson.M{"$gt": afterId}
+	}
+
+	cursor, err := m.conn.Collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}).SetLimit(limit))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var share Share
+		if err = cursor.Decode(&share); err != nil {
+			return err
+		}
+		if err = fn(&share); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// CountSharesByChannel 按渠道统计目标的分享数，没有渠道的历史记录计入空字符串
+func (m *MongoMapper) CountSharesByChannel(ctx context.Context, targetId string, targetType action.TargetType) (map[string]int64, error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_id": targetId, "target_type": targetType}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"$ifNull": bson.A{"$channel", ""}}, "count": bson.M{"$sum": 1}}}},
+	}
+
+	var counts []struct {
+		Channel string `bson:"_id"`
+		Count   int64  `bson:"count"`
+	}
+
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(counts))
+	for _, val := range counts {
+		result[val.Channel] += val.Count
+	}
+
+	return result, nil
+}
+
+// FindByCode 不存在时返回nil
+func (m *MongoMapper) FindByCode(ctx context.Context, code string) (*Share, error) {
+
+	var share Share
+
+	err := m.conn.FindOneNoCache(ctx, &share, bson.M{"code": code})
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &share, nil
+	default:
+		return nil, err
+	}
+}
+
+// IncrStats 给分享的某项统计加一，field为Stats的bson字段名
+func (m *MongoMapper) IncrStats(ctx context.Context, id primitive.ObjectID, field string) error {
+
+	update := bson.M{"$inc": bson.M{"stats." + field: 1}}
+
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, update)
+	return err
+}
+
+// GetUserStats 汇总用户全部分享带来的访问和转化
+func (m *MongoMapper) GetUserStats(ctx context.Context, userId string) (*UserStats, error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userId}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      nil,
+			"shares":   bson.M{"$sum": 1},
+			"visits":   bson.M{"$sum": "$stats.visits"},
+			"sign_ups": bson.M{"$sum": "$stats.sign_ups"},
+			"likes":    bson.M{"$sum": "$stats.likes"},
+		}}},
+	}
+
+	var stats []*UserStats
+
+	err := m.conn.Aggregate(ctx, &stats, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return &UserStats{}, nil
+	}
+
+	return stats[0], nil
+}
+
+// CountSharers 统计分享过目标的不同用户数
+func (m *MongoMapper) CountSharers(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+
+	counts, err := m.BatchCountSharers(ctx, []string{targetId}, targetType)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return counts[targetId], nil
+}
+
+// BatchCountSharers 先按(目标, 用户)去重再按目标计数，没有记录的targetId为0
+func (m *MongoMapper) BatchCountSharers(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error) {
+
+	result := make(map[string]int64, len(targetIds))
+	for _, targetId := range targetIds {
+		result[targetId] = 0
+	}
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"target_id": bson.M{"$in": targetIds}, "target_type": targetType}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"target_id": "$target_id", "user_id": "$user_id"}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$_id.target_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	var counts []struct {
+		TargetId string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+
+	err := m.conn.Aggregate(ctx, &counts, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, val := range counts {
+		result[val.TargetId] = val.Count
+	}
+
+	return result, nil
+}
+
+// GetIdsByTarget 返回指向目标的全部分享的id，用于删除关联的转化记录
+func (m *MongoMapper) GetIdsByTarget(ctx context.Context, targetId string, targetType action.TargetType) ([]string, error) {
+
+	filter := bson.M{"target_id": targetId, "target_type": targetType}
+
+	var shares []*Share
+
+	err := m.conn.Find(ctx, &shares, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(shares))
+	for _, val := range shares {
+		ids = append(ids, val.ID.Hex())
+	}
+	return ids, nil
+}
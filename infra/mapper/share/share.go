@@ -11,7 +11,26 @@ type Share struct {
 	TargetId   string             `bson:"target_id,omitempty" json:"target_id"`
 	TargetType action.TargetType  `bson:"target_type" json:"target_type"`
 	UserId     string             `bson:"user_id,omitempty" json:"user_id"`
+	Channel    string             `bson:"channel,omitempty" json:"channel,omitempty"`   // 为空表示未知渠道
+	Metadata   map[string]string  `bson:"metadata,omitempty" json:"metadata,omitempty"` // 客户端附带的渠道相关信息
+	Code       string             `bson:"code,omitempty" json:"code,omitempty"`         // 分享链接中的短码，支持分享链接之前的记录没有
+	Stats      Stats              `bson:"stats" json:"stats"`
 	CreateAt   time.Time          `bson:"create_at,omitempty" json:"create_at,omitempty"`
 	UpdateAt   time.Time          `bson:"update_at,omitempty" json:"update_at,omitempty"`
 	DeleteAt   time.Time          `bson:"delete_at,omitempty" json:"delete_at,omitempty"`
 }
+
+// Stats 通过分享链接带来的访问和转化
+type Stats struct {
+	Visits  int64 `bson:"visits" json:"visits"`
+	SignUps int64 `bson:"sign_ups" json:"sign_ups"`
+	Likes   int64 `bson:"likes" json:"likes"`
+}
+
+// UserStats 用户全部分享的汇总
+type UserStats struct {
+	Shares  int64 `bson:"shares" json:"shares"`
+	Visits  int64 `bson:"visits" json:"visits"`
+	SignUps int64 `bson:"sign_ups" json:"sign_ups"`
+	Likes   int64 `bson:"likes" json:"likes"`
+}
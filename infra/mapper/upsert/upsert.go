@@ -0,0 +1,21 @@
+package upsert
+
+import (
+	"context"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FindOneAndUpsert 原子地upsert一条记录并把修改前的文档解码到v，原先不存在时返回monc.ErrNotFound。
+// filter需要命中唯一索引，并发upsert时可能触发唯一索引冲突，重试一次即可命中另一请求写入的文档。
+// 事务中的冲突会使事务中止，此时直接返回错误，由调用方重试整个事务
+func FindOneAndUpsert(ctx context.Context, conn *monc.Model, v any, filter any, update any) error {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+	err := conn.FindOneAndUpdateNoCache(ctx, v, filter, update, opts)
+	if mongo.IsDuplicateKeyError(err) && mongo.SessionFromContext(ctx) == nil {
+		err = conn.FindOneAndUpdateNoCache(ctx, v, filter, update, opts)
+	}
+	return err
+}
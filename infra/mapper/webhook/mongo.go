@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/index"
+	"time"
+)
+
+const CollectionName = "webhook"
+
+// Indexes 当前集合需要的全部索引，启动时由NewMongoMapper幂等创建
+var Indexes = []index.Index{
+	// FindMatched，两个数组字段不能建在同一个索引中
+	{Name: "topics", Keys: bson.D{{Key: "topics", Value: 1}}},
+}
+
+// 用于检查接口是否实现
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type IMongoMapper interface {
+	InsertOne(ctx context.Context, url string, topics []string, targetTypes []action.TargetType, secret string) (*Webhook, error)
+	FindOne(ctx context.Context, id string) (*Webhook, error)
+	DeleteOne(ctx context.Context, id string) error
+	FindAll(ctx context.Context) ([]*Webhook, error)
+	FindMatched(ctx context.Context, topic string, targetType action.TargetType) ([]*Webhook, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper() IMongoMapper {
+	aConfig := config.Get()
+	conn := monc.MustNewModel(aConfig.Mongo.URL, aConfig.Mongo.DB, CollectionName, aConfig.Cache)
+	index.Ensure(conn, CollectionName, Indexes)
+
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func (m *MongoMapper) InsertOne(ctx context.Context, url string, topics []string, targetTypes []action.TargetType, secret string) (*Webhook, error) {
+
+	// 空过滤条件需要存为[]才能被$size匹配
+	if topics == nil {
+		topics = []string{}
+	}
+	if targetTypes == nil {
+		targetTypes = []action.TargetType{}
+	}
+
+	newWebhook := &Webhook{
+		ID:          primitive.NewObjectID(),
+		URL:         url,
+		Topics:      topics,
+		TargetTypes: targetTypes,
+		Secret:      secret,
+		CreateAt:    time.Now(),
+		UpdateAt:    time.Now(),
+	}
+
+	_, err := m.conn.InsertOneNoCache(ctx, newWebhook)
+	if err != nil {
+		return nil, err
+	}
+	return newWebhook, nil
+}
+
+// FindOne 不存在时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, id string) (*Webhook, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var webhook Webhook
+
+	err = m.conn.FindOneNoCache(ctx, &webhook, bson.M{"_id": oid})
+	switch {
+	case errors.Is(err, monc.ErrNotFound):
+		return nil, nil
+	case err == nil:
+		return &webhook, nil
+	default:
+		return nil, err
+	}
+}
+
+func (m *MongoMapper) DeleteOne(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+
+	_, err = m.conn.DeleteOneNoCache(ctx, bson.M{"_id": oid})
+	return err
+}
+
+func (m *MongoMapper) FindAll(ctx context.Context) ([]*Webhook, error) {
+	var webhooks []*Webhook
+
+	err := m.conn.Find(ctx, &webhooks, bson.M{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// FindMatched 返回订阅了topic且关注targetType的回调
+func (m *MongoMapper) FindMatched(ctx context.Context, topic string, targetType action.TargetType) ([]*Webhook, error) {
+	filter := bson.M{"$and": bson.A{
+		bson.M{"$or": bson.A{bson.M{"topics": topic}, bson.M{"topics": bson.M{"$size": 0}}}},
+		bson.M{"$or": bson.A{bson.M{"target_types": targetType}, bson.M{"target_types": bson.M{"$size": 0}}}},
+	}}
+
+	var webhooks []*Webhook
+
+	err := m.conn.Find(ctx, &webhooks, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
@@ -0,0 +1,18 @@
+package webhook
+
+import (
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Webhook 订阅行为事件的回调地址，Topics和TargetTypes为空表示不过滤
+type Webhook struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	URL         string              `bson:"url" json:"url"`
+	Topics      []string            `bson:"topics" json:"topics"`
+	TargetTypes []action.TargetType `bson:"target_types" json:"target_types"`
+	Secret      string              `bson:"secret" json:"-"`
+	CreateAt    time.Time           `bson:"create_at,omitempty" json:"create_at,omitempty"`
+	UpdateAt    time.Time           `bson:"update_at,omitempty" json:"update_at,omitempty"`
+}
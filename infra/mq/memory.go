@@ -0,0 +1,40 @@
+package mq
+
+import (
+	"context"
+	"sync"
+)
+
+type Handler func(ctx context.Context, topic string, payload []byte) error
+
+// MemoryPublisher 进程内的消息队列，同步调用订阅者，没有订阅者时消息被丢弃，只用于本地开发和测试
+type MemoryPublisher struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// Subscribe 订阅topic，topic为空时订阅全部消息
+func (p *MemoryPublisher) Subscribe(topic string, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[topic] = append(p.handlers[topic], handler)
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.RLock()
+	handlers := append(append([]Handler{}, p.handlers[topic]...), p.handlers[""]...)
+	p.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, topic, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
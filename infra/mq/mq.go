@@ -0,0 +1,32 @@
+package mq
+
+import (
+	"context"
+	"github.com/zeromicro/go-zero/core/service"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"meowcloud-action/common/config"
+)
+
+const (
+	BrokerMemory = "memory"
+	BrokerRedis  = "redis"
+)
+
+// Publisher 消息队列的抽象，Publish返回nil表示消息已被队列接收
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// NewPublisher 根据配置创建Publisher，memory没有订阅者时会丢弃消息，pro模式下直接panic
+func NewPublisher() Publisher {
+	aConfig := config.Get()
+	switch aConfig.Outbox.Broker {
+	case BrokerMemory:
+		if aConfig.Mode == service.ProMode {
+			panic("outbox broker memory is not allowed in pro mode")
+		}
+		return NewMemoryPublisher()
+	default:
+		return NewRedisStreamPublisher(redis.MustNewRedis(aConfig.Cache[0].RedisConf), aConfig.Outbox.Stream)
+	}
+}
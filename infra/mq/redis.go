@@ -0,0 +1,27 @@
+package mq
+
+import (
+	"context"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+// go-zero的redis没有封装XADD，使用脚本写入
+var xaddScript = redis.NewScript(`return redis.call('XADD', KEYS[1], '*', 'topic', ARGV[1], 'payload', ARGV[2])`)
+
+// RedisStreamPublisher 把消息写入redis stream，消费方通过XREADGROUP消费
+type RedisStreamPublisher struct {
+	rds    *redis.Redis
+	stream string
+}
+
+func NewRedisStreamPublisher(rds *redis.Redis, stream string) *RedisStreamPublisher {
+	return &RedisStreamPublisher{
+		rds:    rds,
+		stream: stream,
+	}
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	_, err := p.rds.ScriptRunCtx(ctx, xaddScript, []string{p.stream}, topic, string(payload))
+	return err
+}
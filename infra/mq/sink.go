@@ -0,0 +1,14 @@
+package mq
+
+// Sink 带名称的Publisher，outbox按名称记录已成功投递的下游，重试时只投递失败的下游
+type Sink struct {
+	Name      string
+	Publisher Publisher
+}
+
+func NewSink(name string, publisher Publisher) Sink {
+	return Sink{
+		Name:      name,
+		Publisher: publisher,
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/event"
+	"strings"
+	"time"
+)
+
+const prefixRateLimitKey = "ratelimit"
+
+// ShareVisit 匿名访问分享链接的规则名，按分享码限流
+const ShareVisit = "share_visit"
+
+// 令牌桶状态保存在hash中，按两次请求的时间差补充令牌，桶满后闲置足够久的key会过期
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`)
+
+type rule struct {
+	key   string
+	rate  float64
+	burst int64
+}
+
+// redisKey 令牌桶在redis中的key，subject为用户id或分享码等被限流的对象
+func (r rule) redisKey(subject string) string {
+	return fmt.Sprintf("%s:%s:%s", prefixRateLimitKey, r.key, subject)
+}
+
+// newRules 把配置整理为按"行为"或"行为:目标类型"索引的规则，忽略速率或容量不为正的配置
+func newRules(limits []config.RateLimit) map[string]rule {
+	rules := make(map[string]rule, len(limits))
+	for _, val := range limits {
+		if val.Rate <= 0 || val.Burst <= 0 {
+			continue
+		}
+		key := val.Action
+		if val.TargetType != "" {
+			key = val.Action + ":" + strings.ToUpper(val.TargetType)
+		}
+		rules[key] = rule{key: key, rate: val.Rate, burst: val.Burst}
+	}
+	return rules
+}
+
+// match 优先使用指定了目标类型的规则，其次是对该行为全部目标类型生效的规则，targetType为空时只匹配后者
+func match(rules map[string]rule, act string, targetType string) (rule, bool) {
+	if targetType != "" {
+		if r, ok := rules[act+":"+targetType]; ok {
+			return r, true
+		}
+	}
+	r, ok := rules[act]
+	return r, ok
+}
+
+// Limiter 按(用户, 行为, 目标类型)限流，redis不可用时放行
+type Limiter struct {
+	rds   *redis.Redis
+	rules map[string]rule
+}
+
+func NewLimiter() *Limiter {
+	aConfig := config.Get()
+
+	limiter := &Limiter{rules: newRules(aConfig.RateLimits)}
+	if len(limiter.rules) > 0 {
+		limiter.rds = redis.MustNewRedis(aConfig.Cache[0].RedisConf)
+	}
+	return limiter
+}
+
+// Allow 消耗一个令牌，返回false表示超过限制，没有匹配的规则时总是放行
+func (l *Limiter) Allow(ctx context.Context, act event.Action, targetType action.TargetType, userId string) bool {
+	r, ok := match(l.rules, string(act), targetType.String())
+	if !ok {
+		return true
+	}
+
+	return l.take(ctx, r, userId)
+}
+
+// AllowKey 对没有用户的请求按key限流，如按分享码限制匿名访问，没有名为name的规则时总是放行
+func (l *Limiter) AllowKey(ctx context.Context, name string, key string) bool {
+	r, ok := match(l.rules, name, "")
+	if !ok {
+		return true
+	}
+
+	return l.take(ctx, r, key)
+}
+
+func (l *Limiter) take(ctx context.Context, r rule, subject string) bool {
+	allowed, err := l.rds.ScriptRunCtx(ctx, tokenBucketScript, []string{r.redisKey(subject)}, r.rate, r.burst, time.Now().UnixMilli())
+	if err != nil {
+		// 限流只是保护措施，redis故障时不影响正常请求
+		log.CtxError(ctx, "限流检查失败，放行请求: %v", err)
+		return true
+	}
+
+	n, _ := allowed.(int64)
+	return n == 1
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"meowcloud-action/common/config"
+	"testing"
+)
+
+func TestNewRules(t *testing.T) {
+	rules := newRules([]config.RateLimit{
+		{Action: "like", Rate: 1, Burst: 30},
+		{Action: "like", TargetType: "photo", Rate: 2, Burst: 10},
+		{Action: "follow", Rate: 0, Burst: 10},
+		{Action: "share", Rate: 1, Burst: 0},
+	})
+
+	if len(rules) != 2 {
+		t.Fatalf("len(rules) = %d, want 2", len(rules))
+	}
+	if r, ok := rules["like:PHOTO"]; !ok || r.rate != 2 || r.burst != 10 {
+		t.Errorf("rules[like:PHOTO] = %+v, %v", r, ok)
+	}
+	if _, ok := rules["follow"]; ok {
+		t.Error("rate为0的规则应被忽略")
+	}
+	if _, ok := rules["share"]; ok {
+		t.Error("burst为0的规则应被忽略")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules := newRules([]config.RateLimit{
+		{Action: "like", Rate: 1, Burst: 30},
+		{Action: "like", TargetType: "PHOTO", Rate: 2, Burst: 10},
+		{Action: "follow", TargetType: "USER", Rate: 0.5, Burst: 20},
+		{Action: ShareVisit, Rate: 1, Burst: 60},
+	})
+
+	tests := []struct {
+		name       string
+		act        string
+		targetType string
+		wantKey    string
+		wantOk     bool
+	}{
+		{name: "指定了目标类型的规则优先", act: "like", targetType: "PHOTO", wantKey: "like:PHOTO", wantOk: true},
+		{name: "没有目标类型的规则时使用行为的规则", act: "like", targetType: "POST", wantKey: "like", wantOk: true},
+		{name: "没有行为的规则时不匹配其他目标类型", act: "follow", targetType: "POST", wantOk: false},
+		{name: "目标类型完全匹配", act: "follow", targetType: "USER", wantKey: "follow:USER", wantOk: true},
+		{name: "目标类型为空时只匹配行为的规则", act: ShareVisit, targetType: "", wantKey: ShareVisit, wantOk: true},
+		{name: "未配置的行为不限流", act: "favorite", targetType: "PHOTO", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := match(rules, tt.act, tt.targetType)
+			if ok != tt.wantOk {
+				t.Fatalf("match() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && r.key != tt.wantKey {
+				t.Errorf("match() key = %q, want %q", r.key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestRedisKey(t *testing.T) {
+	rules := newRules([]config.RateLimit{{Action: "like", TargetType: "photo", Rate: 1, Burst: 1}})
+
+	r, _ := match(rules, "like", "PHOTO")
+	if got, want := r.redisKey("user1"), "ratelimit:like:PHOTO:user1"; got != want {
+		t.Errorf("redisKey() = %q, want %q", got, want)
+	}
+}
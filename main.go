@@ -4,6 +4,8 @@ import (
 	"github.com/xh-polaris/meowchat-content/biz/infrastructure/util/log"
 	"meowcloud-action/common/config"
 	"meowcloud-action/controller"
+	"meowcloud-action/infra/mq"
+	"meowcloud-action/service"
 	"net"
 
 	"github.com/cloudwego/kitex/pkg/klog"
@@ -13,6 +15,7 @@ import (
 	"github.com/xh-polaris/gopkg/kitex/middleware"
 	logx "github.com/xh-polaris/gopkg/util/log"
 	action "github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action/actionservice"
+	"github.com/zeromicro/go-zero/core/threading"
 )
 
 func main() {
@@ -26,6 +29,17 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+
+	// 把outbox中的行为事件投递到消息队列，同时拆分为各webhook的投递任务
+	relay := service.NewOutboxRelay(mq.NewSink("broker", mq.NewPublisher()), mq.NewSink("webhook", service.NewWebhookService()))
+	threading.GoSafe(relay.Start)
+	defer relay.Stop()
+
+	// 回调webhook，失败时按退避策略重试
+	worker := service.NewWebhookWorker()
+	threading.GoSafe(worker.Start)
+	defer worker.Stop()
+
 	svr := action.NewServer(
 		controller.NewActionController(),
 		server.WithServiceAddr(addr),
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/follow"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/pagination"
+)
+
+type IBlockService interface {
+	DoBlock(ctx context.Context, targetId string, userId string) (*dto.DoBlockResp, error)
+	CancelBlock(ctx context.Context, targetId string, userId string) (*dto.CancelBlockResp, error)
+	GetBlocked(ctx context.Context, targetId string, userId string) (*dto.GetBlockedResp, error)
+	GetBlockedUsers(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetBlockedUsersResp, error)
+}
+
+type BlockService struct {
+	BlockMongoMapper  block.IMongoMapper
+	EventMongoMapper  event.IMongoMapper
+	OutboxMongoMapper outbox.IMongoMapper
+	FollowMongoMapper follow.IMongoMapper
+	// 拉黑时通过FollowService取消关注，以便同步修正计数和产生事件
+	FollowService IFollowService
+}
+
+func NewBlockService() IBlockService {
+	mongoMapper := block.NewMongoMapper()
+	return &BlockService{
+		BlockMongoMapper:  mongoMapper,
+		EventMongoMapper:  event.NewMongoMapper(),
+		OutboxMongoMapper: outbox.NewMongoMapper(),
+		FollowMongoMapper: follow.NewMongoMapper(),
+		FollowService:     NewFollowService(),
+	}
+}
+
+func (service *BlockService) DoBlock(ctx context.Context, targetId string, userId string) (*dto.DoBlockResp, error) {
+
+	if targetId == userId {
+		return nil, consts.BlockSelf
+	}
+
+	// upsert是原子的，并发请求中只有一个能使拉黑生效
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, nil, service.EventMongoMapper, "", event.Block, event.Do, targetId, action.TargetType_USER, userId, func(ctx context.Context) (bool, error) {
+		return service.BlockMongoMapper.InsertOne(ctx, targetId, userId)
+	})
+
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	// 取消双向关注，拉黑已经生效，之后的关注会被DoFollow拒绝。
+	// 拉黑过时同样执行，上次请求可能在拉黑生效后、取消关注前失败，由客户端重试补齐
+	if err = service.unfollow(ctx, targetId, userId); err != nil {
+		return nil, err
+	}
+	if err = service.unfollow(ctx, userId, targetId); err != nil {
+		return nil, err
+	}
+
+	// 拉黑过则抛出异常
+	if !ok {
+		return nil, consts.RepeatBlock
+	}
+
+	return &dto.DoBlockResp{}, nil
+}
+
+// unfollow 拒绝userId对targetId尚未处理的关注请求，并取消userId对targetId的关注，是幂等的，没有关注或请求时什么都不做。
+// 先拒绝请求，与之并发的通过请求会因写冲突重试并看到拉黑，在拒绝之前已经通过的请求由随后的取消关注处理
+func (service *BlockService) unfollow(ctx context.Context, targetId string, userId string) error {
+	if _, err := service.FollowMongoMapper.RejectRequest(ctx, targetId, userId); err != nil {
+		return err
+	}
+	_, err := service.FollowService.CancelFollow(ctx, targetId, action.TargetType_USER, userId)
+	if err != nil && !errors.Is(err, consts.FollowNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (service *BlockService) CancelBlock(ctx context.Context, targetId string, userId string) (*dto.CancelBlockResp, error) {
+
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, nil, service.EventMongoMapper, "", event.Block, event.Cancel, targetId, action.TargetType_USER, userId, func(ctx context.Context) (bool, error) {
+		return service.BlockMongoMapper.CancelBlock(ctx, targetId, userId)
+	})
+
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	// 未拉黑过则抛出异常
+	if !ok {
+		return nil, consts.BlockNotExist
+	}
+
+	return &dto.CancelBlockResp{}, nil
+}
+
+func (service *BlockService) GetBlocked(ctx context.Context, targetId string, userId string) (*dto.GetBlockedResp, error) {
+	blocked, err := service.BlockMongoMapper.IsBlocked(ctx, targetId, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetBlockedResp{Blocked: blocked}, nil
+}
+
+func (service *BlockService) GetBlockedUsers(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetBlockedUsersResp, error) {
+	data, total, lastToken, err := service.BlockMongoMapper.GetBlockedUsers(ctx, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*dto.Block, 0, len(data))
+	for _, val := range data {
+		blocks = append(blocks, &dto.Block{
+			Id:       val.ID.Hex(),
+			TargetId: val.TargetId,
+			UserId:   val.UserId,
+			CreateAt: val.CreateAt.Unix(),
+		})
+	}
+
+	return &dto.GetBlockedUsersResp{
+		Blocks:  blocks,
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// checkBlocked 任意一方拉黑了另一方都不能互动，只有目标是用户时才知道对方是谁，其他目标的作者需要由调用方校验。
+// 在withOutbox的事务中调用时，返回的consts.Blocked会使事务回滚，避免与并发的拉黑交错
+func checkBlocked(ctx context.Context, blockMapper block.IMongoMapper, targetId string, targetType action.TargetType, userId string) error {
+	if targetType != action.TargetType_USER {
+		return nil
+	}
+
+	blocked, err := blockMapper.IsEitherBlocked(ctx, targetId, userId)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return consts.Blocked
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
+)
+
+type countFunc func(ctx context.Context, targetId string, targetType action.TargetType) (int64, error)
+
+type batchCountFunc func(ctx context.Context, targetIds []string, targetType action.TargetType) (map[string]int64, error)
+
+// getCount 优先读取物化计数，计数未初始化时从明细统计并回填
+func getCount(ctx context.Context, counterMapper counter.IMongoMapper, targetId string, targetType action.TargetType, kind counter.Kind, fallback countFunc) (int64, error) {
+	count, ok, err := counterMapper.Get(ctx, targetId, targetType, kind)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return count, nil
+	}
+
+	return counterMapper.Init(ctx, targetId, targetType, kind, func(ctx context.Context) (int64, error) {
+		return fallback(ctx, targetId, targetType)
+	})
+}
+
+// batchGetCount 批量读取物化计数，未初始化的目标通过一次聚合统计并用一次BulkWrite回填
+func batchGetCount(ctx context.Context, counterMapper counter.IMongoMapper, targetIds []string, targetType action.TargetType, kind counter.Kind, fallback batchCountFunc) (map[string]int64, error) {
+	counts, err := counterMapper.BatchGet(ctx, targetIds, targetType, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, targetId := range targetIds {
+		if _, ok := counts[targetId]; !ok {
+			missing = append(missing, targetId)
+		}
+	}
+	if len(missing) == 0 {
+		return counts, nil
+	}
+
+	initCounts, err := counterMapper.BatchInit(ctx, missing, targetType, kind, func(ctx context.Context) (map[string]int64, error) {
+		return fallback(ctx, missing, targetType)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for targetId, count := range initCounts {
+		counts[targetId] = count
+	}
+	return counts, nil
+}
+
+// reconcileCount 从明细重新统计并覆盖计数
+func reconcileCount(ctx context.Context, counterMapper counter.IMongoMapper, targetId string, targetType action.TargetType, kind counter.Kind, fallback countFunc) (int64, error) {
+	return counterMapper.Reconcile(ctx, targetId, targetType, kind, func(ctx context.Context) (int64, error) {
+		return fallback(ctx, targetId, targetType)
+	})
+}
+
+// countDelta 行为生效时计数加一，取消时减一
+func countDelta(op event.Op) int64 {
+	if op == event.Cancel {
+		return -1
+	}
+	return 1
+}
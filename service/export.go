@@ -0,0 +1,112 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"strconv"
+	"time"
+)
+
+var csvHeader = []string{"action", "target_id", "target_type", "user_id", "is_cancel", "create_at", "update_at", "delete_at", "channel", "reaction"}
+
+// exportToken 分段导出的位置，Source为exportSources中的下标，LastId为该集合已导出的最后一条记录
+type exportToken struct {
+	Source int                `json:"source"`
+	LastId primitive.ObjectID `json:"last_id"`
+}
+
+func encodeExportToken(t exportToken) (string, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeExportToken 空token表示从头开始
+func decodeExportToken(s string) (exportToken, error) {
+	var t exportToken
+	if s == "" {
+		return t, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, consts.InvalidExportToken
+	}
+	if err = json.Unmarshal(raw, &t); err != nil || t.Source < 0 {
+		return t, consts.InvalidExportToken
+	}
+	return t, nil
+}
+
+type recordWriter interface {
+	Write(record *dto.ActionRecord) error
+	Flush() error
+}
+
+// newRecordWriter header为false时不写csv的表头，用于分段导出的后续段
+func newRecordWriter(format string, w io.Writer, header bool) (recordWriter, error) {
+	switch format {
+	case "", consts.ExportFormatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	case consts.ExportFormatCSV:
+		writer := csv.NewWriter(w)
+		if header {
+			if err := writer.Write(csvHeader); err != nil {
+				return nil, err
+			}
+		}
+		return &csvWriter{writer: writer}, nil
+	default:
+		return nil, consts.FormatNotSupport
+	}
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(record *dto.ActionRecord) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(record *dto.ActionRecord) error {
+	return w.writer.Write([]string{
+		record.Action,
+		record.TargetId,
+		record.TargetType.String(),
+		record.UserId,
+		strconv.FormatBool(record.IsCancel),
+		strconv.FormatInt(record.CreateAt, 10),
+		strconv.FormatInt(record.UpdateAt, 10),
+		strconv.FormatInt(record.DeleteAt, 10),
+		record.Channel,
+		record.Reaction,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// unix 零值时间导出为0
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/mongo"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/collection"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/favorite"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/pagination"
+	"strings"
+)
+
+type IFavoriteService interface {
+	DoFavorite(ctx context.Context, targetId string, targetType action.TargetType, userId string, collectionId string) (*dto.DoFavoriteResp, error)
+	CancelFavorite(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.CancelFavoriteResp, error)
+	GetFavorited(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.GetFavoritedResp, error)
+	BatchGetFavorited(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetFavoritedResp, error)
+	GetFavoritedCount(ctx context.Context, targetId string, targetType action.TargetType) (*dto.GetFavoritedCountResp, error)
+	BatchGetFavoritedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetFavoritedCountResp, error)
+	CreateCollection(ctx context.Context, userId string, name string) (*dto.CreateFavoriteCollectionResp, error)
+	RenameCollection(ctx context.Context, collectionId string, userId string, name string) (*dto.RenameFavoriteCollectionResp, error)
+	DeleteCollection(ctx context.Context, collectionId string, userId string) (*dto.DeleteFavoriteCollectionResp, error)
+	ReorderCollections(ctx context.Context, userId string, collectionIds []string) (*dto.ReorderFavoriteCollectionsResp, error)
+	GetCollections(ctx context.Context, userId string) (*dto.GetFavoriteCollectionsResp, error)
+	GetCollectionFavorites(ctx context.Context, collectionId string, userId string, options *basic.PaginationOptions) (*dto.GetCollectionFavoritesResp, error)
+}
+
+type FavoriteService struct {
+	FavoriteMongoMapper   favorite.IMongoMapper
+	CollectionMongoMapper collection.IMongoMapper
+	CounterMongoMapper    counter.IMongoMapper
+	EventMongoMapper      event.IMongoMapper
+	OutboxMongoMapper     outbox.IMongoMapper
+	BlockMongoMapper      block.IMongoMapper
+}
+
+func NewFavoriteService() IFavoriteService {
+	mongoMapper := favorite.NewMongoMapper()
+	return &FavoriteService{
+		FavoriteMongoMapper:   mongoMapper,
+		CollectionMongoMapper: collection.NewMongoMapper(),
+		CounterMongoMapper:    counter.NewMongoMapper(),
+		EventMongoMapper:      event.NewMongoMapper(),
+		OutboxMongoMapper:     outbox.NewMongoMapper(),
+		BlockMongoMapper:      block.NewMongoMapper(),
+	}
+}
+
+// checkCollection collectionId为空表示未分组，否则需要是当前用户的收藏夹
+func (service *FavoriteService) checkCollection(ctx context.Context, collectionId string, userId string) error {
+	if collectionId == "" {
+		return nil
+	}
+
+	data, err := service.CollectionMongoMapper.FindOne(ctx, collectionId, userId)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return consts.CollectionNotExist
+	}
+	return nil
+}
+
+// DoFavorite 每个用户对每个目标只有一条收藏，已收藏在其他收藏夹时移动到collectionId
+func (service *FavoriteService) DoFavorite(ctx context.Context, targetId string, targetType action.TargetType, userId string, collectionId string) (*dto.DoFavoriteResp, error) {
+
+	// upsert是原子的，并发请求中只有一个能使收藏生效，移动收藏夹不产生消息
+	var old *favorite.Favorite
+	var missing bool
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Favorite, event.Favorite, event.Do, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		if err := checkBlocked(ctx, service.BlockMongoMapper, targetId, targetType, userId); err != nil {
+			return false, err
+		}
+		// 在同一事务中校验收藏夹，与DeleteCollection产生写冲突，收藏夹被并发删除时重试后返回不存在
+		if collectionId != "" {
+			exists, err := service.CollectionMongoMapper.Touch(ctx, collectionId, userId)
+			if err != nil {
+				return false, err
+			}
+			if missing = !exists; missing {
+				return false, nil
+			}
+		}
+
+		var err error
+		old, err = service.FavoriteMongoMapper.InsertOne(ctx, targetId, targetType, userId, collectionId)
+		return err == nil && (old == nil || old.IsCancel), err
+	})
+
+	if errors.Is(err, consts.Blocked) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	if missing {
+		return nil, consts.CollectionNotExist
+	}
+
+	if ok {
+		return &dto.DoFavoriteResp{}, nil
+	}
+
+	// 已收藏在同一个收藏夹则抛出异常
+	if old.CollectionId == collectionId {
+		return nil, consts.RepeatFavorite
+	}
+
+	return &dto.DoFavoriteResp{Moved: true, PreviousCollectionId: old.CollectionId}, nil
+}
+
+func (service *FavoriteService) CancelFavorite(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.CancelFavoriteResp, error) {
+
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Favorite, event.Favorite, event.Cancel, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		return service.FavoriteMongoMapper.CancelFavorite(ctx, targetId, targetType, userId)
+	})
+
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	// 未收藏过则抛出异常
+	if !ok {
+		return nil, consts.FavoriteNotExist
+	}
+
+	return &dto.CancelFavoriteResp{}, nil
+}
+
+func (service *FavoriteService) GetFavorited(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.GetFavoritedResp, error) {
+	data, err := service.FavoriteMongoMapper.FindOne(ctx, targetId, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return &dto.GetFavoritedResp{}, nil
+	}
+
+	return &dto.GetFavoritedResp{Favorited: true, CollectionId: data.CollectionId}, nil
+}
+
+func (service *FavoriteService) BatchGetFavorited(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetFavoritedResp, error) {
+	favorited, err := service.FavoriteMongoMapper.BatchIsFavorited(ctx, targetIds, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetFavoritedResp{Favorited: favorited}, nil
+}
+
+func (service *FavoriteService) GetFavoritedCount(ctx context.Context, targetId string, targetType action.TargetType) (*dto.GetFavoritedCountResp, error) {
+	count, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Favorite, service.FavoriteMongoMapper.CountFavorites)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetFavoritedCountResp{Count: count}, nil
+}
+
+func (service *FavoriteService) BatchGetFavoritedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetFavoritedCountResp, error) {
+	counts, err := batchGetCount(ctx, service.CounterMongoMapper, targetIds, targetType, counter.Favorite, service.FavoriteMongoMapper.BatchCountFavorites)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetFavoritedCountResp{Counts: counts}, nil
+}
+
+// CreateCollection 在同一事务中检查数量上限并新建收藏夹，InsertOne写用户的锁文档使同一用户的并发新建串行执行，
+// 不会超过上限。锁文档被并发创建时的唯一索引冲突会中止事务，此时重试整个事务
+func (service *FavoriteService) CreateCollection(ctx context.Context, userId string, name string) (*dto.CreateFavoriteCollectionResp, error) {
+	var data *collection.Collection
+	var err error
+	for i := 0; i < maxOutboxRetries; i++ {
+		err = service.CollectionMongoMapper.Transaction(ctx, func(ctx context.Context) error {
+			count, err := service.CollectionMongoMapper.CountByUserId(ctx, userId)
+			if err != nil {
+				return err
+			}
+			if count >= consts.MaxFavoriteCollections {
+				return consts.TooManyCollections
+			}
+			data, err = service.CollectionMongoMapper.InsertOne(ctx, userId, strings.TrimSpace(name))
+			return err
+		})
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+
+	if errors.Is(err, consts.TooManyCollections) {
+		return nil, err
+	}
+
+	if errors.Is(err, collection.ErrNameExists) {
+		return nil, consts.RepeatCollectionName
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreateFavoriteCollectionResp{Collection: toFavoriteCollection(data, 0)}, nil
+}
+
+func (service *FavoriteService) RenameCollection(ctx context.Context, collectionId string, userId string, name string) (*dto.RenameFavoriteCollectionResp, error) {
+	ok, err := service.CollectionMongoMapper.Rename(ctx, collectionId, userId, strings.TrimSpace(name))
+
+	if errors.Is(err, collection.ErrNameExists) {
+		return nil, consts.RepeatCollectionName
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, consts.CollectionNotExist
+	}
+
+	return &dto.RenameFavoriteCollectionResp{}, nil
+}
+
+// DeleteCollection 在同一事务中把收藏移动到未分组并删除收藏夹，并发的DoFavorite会与之产生写冲突，
+// 不会留下指向不存在收藏夹的收藏
+func (service *FavoriteService) DeleteCollection(ctx context.Context, collectionId string, userId string) (*dto.DeleteFavoriteCollectionResp, error) {
+	if collectionId == "" {
+		return nil, consts.CollectionNotExist
+	}
+
+	if err := service.checkCollection(ctx, collectionId, userId); err != nil {
+		return nil, err
+	}
+
+	var moved int64
+	err := service.CollectionMongoMapper.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if moved, err = service.FavoriteMongoMapper.MoveCollection(ctx, userId, collectionId, ""); err != nil {
+			return err
+		}
+		ok, err := service.CollectionMongoMapper.DeleteOne(ctx, collectionId, userId)
+		if err != nil {
+			return err
+		}
+		// 已被并发删除时回滚移动
+		if !ok {
+			return consts.CollectionNotExist
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.DeleteFavoriteCollectionResp{Moved: moved}, nil
+}
+
+func (service *FavoriteService) ReorderCollections(ctx context.Context, userId string, collectionIds []string) (*dto.ReorderFavoriteCollectionsResp, error) {
+	data, err := service.CollectionMongoMapper.GetByUserId(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// 新的顺序需要恰好包含用户的全部收藏夹，避免位置重复
+	if len(collectionIds) != len(data) {
+		return nil, consts.InvalidCollectionOrder
+	}
+	remaining := make(map[string]struct{}, len(data))
+	for _, val := range data {
+		remaining[val.ID.Hex()] = struct{}{}
+	}
+	for _, id := range collectionIds {
+		if _, ok := remaining[id]; !ok {
+			return nil, consts.InvalidCollectionOrder
+		}
+		delete(remaining, id)
+	}
+
+	err = service.CollectionMongoMapper.Reorder(ctx, userId, collectionIds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ReorderFavoriteCollectionsResp{}, nil
+}
+
+func (service *FavoriteService) GetCollections(ctx context.Context, userId string) (*dto.GetFavoriteCollectionsResp, error) {
+	data, err := service.CollectionMongoMapper.GetByUserId(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := service.FavoriteMongoMapper.CountByCollections(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]*dto.FavoriteCollection, 0, len(data))
+	for _, val := range data {
+		collections = append(collections, toFavoriteCollection(val, counts[val.ID.Hex()]))
+	}
+
+	return &dto.GetFavoriteCollectionsResp{
+		Collections: collections,
+		Ungrouped:   counts[""],
+	}, nil
+}
+
+func (service *FavoriteService) GetCollectionFavorites(ctx context.Context, collectionId string, userId string, options *basic.PaginationOptions) (*dto.GetCollectionFavoritesResp, error) {
+	if err := service.checkCollection(ctx, collectionId, userId); err != nil {
+		return nil, err
+	}
+
+	data, total, lastToken, err := service.FavoriteMongoMapper.GetByCollection(ctx, userId, collectionId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	favorites := make([]*dto.Favorite, 0, len(data))
+	for _, val := range data {
+		favorites = append(favorites, &dto.Favorite{
+			Id:           val.ID.Hex(),
+			TargetId:     val.TargetId,
+			TargetType:   val.TargetType,
+			UserId:       val.UserId,
+			CollectionId: val.CollectionId,
+			CreateAt:     val.CreateAt.Unix(),
+		})
+	}
+
+	return &dto.GetCollectionFavoritesResp{
+		Favorites: favorites,
+		Total:     total,
+		Token:     lastToken,
+		HasMore:   pagination.HasMore(lastToken),
+	}, nil
+}
+
+func toFavoriteCollection(val *collection.Collection, count int64) *dto.FavoriteCollection {
+	return &dto.FavoriteCollection{
+		Id:       val.ID.Hex(),
+		Name:     val.Name,
+		Position: val.Position,
+		Count:    count,
+		CreateAt: val.CreateAt.Unix(),
+	}
+}
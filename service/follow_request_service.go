@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/follow"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/pagination"
+	"meowcloud-action/infra/mapper/privacy"
+)
+
+type IFollowRequestService interface {
+	SetFollowPrivacy(ctx context.Context, userId string, private bool) (*dto.SetFollowPrivacyResp, error)
+	GetFollowPrivacy(ctx context.Context, userId string) (*dto.GetFollowPrivacyResp, error)
+	GetIncomingRequests(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetFollowRequestsResp, error)
+	GetOutgoingRequests(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetFollowRequestsResp, error)
+	ApproveFollowRequest(ctx context.Context, targetId string, userId string) (*dto.HandleFollowRequestResp, error)
+	RejectFollowRequest(ctx context.Context, targetId string, userId string) (*dto.HandleFollowRequestResp, error)
+	WithdrawFollowRequest(ctx context.Context, targetId string, userId string) (*dto.WithdrawFollowRequestResp, error)
+}
+
+type FollowRequestService struct {
+	FollowMongoMapper  follow.IMongoMapper
+	PrivacyMongoMapper privacy.IMongoMapper
+	CounterMongoMapper counter.IMongoMapper
+	EventMongoMapper   event.IMongoMapper
+	OutboxMongoMapper  outbox.IMongoMapper
+	BlockMongoMapper   block.IMongoMapper
+}
+
+func NewFollowRequestService() IFollowRequestService {
+	return &FollowRequestService{
+		FollowMongoMapper:  follow.NewMongoMapper(),
+		PrivacyMongoMapper: privacy.NewMongoMapper(),
+		CounterMongoMapper: counter.NewMongoMapper(),
+		EventMongoMapper:   event.NewMongoMapper(),
+		OutboxMongoMapper:  outbox.NewMongoMapper(),
+		BlockMongoMapper:   block.NewMongoMapper(),
+	}
+}
+
+// SetFollowPrivacy 改为公开后已有的请求仍需处理，不会自动通过
+func (service *FollowRequestService) SetFollowPrivacy(ctx context.Context, userId string, private bool) (*dto.SetFollowPrivacyResp, error) {
+	err := service.PrivacyMongoMapper.SetPrivate(ctx, userId, private)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SetFollowPrivacyResp{}, nil
+}
+
+func (service *FollowRequestService) GetFollowPrivacy(ctx context.Context, userId string) (*dto.GetFollowPrivacyResp, error) {
+	private, err := service.PrivacyMongoMapper.IsPrivate(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetFollowPrivacyResp{Private: private}, nil
+}
+
+func (service *FollowRequestService) GetIncomingRequests(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetFollowRequestsResp, error) {
+	data, total, lastToken, err := service.FollowMongoMapper.GetIncomingRequests(ctx, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetFollowRequestsResp{
+		Requests: toFollowRequests(data),
+		Total:    total,
+		Token:    lastToken,
+		HasMore:  pagination.HasMore(lastToken),
+	}, nil
+}
+
+func (service *FollowRequestService) GetOutgoingRequests(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetFollowRequestsResp, error) {
+	data, total, lastToken, err := service.FollowMongoMapper.GetOutgoingRequests(ctx, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetFollowRequestsResp{
+		Requests: toFollowRequests(data),
+		Total:    total,
+		Token:    lastToken,
+		HasMore:  pagination.HasMore(lastToken),
+	}, nil
+}
+
+// ApproveFollowRequest targetId通过userId的请求，与DoFollow一样产生关注事件并增加计数，请求发出后任意一方拉黑了对方时不能通过
+func (service *FollowRequestService) ApproveFollowRequest(ctx context.Context, targetId string, userId string) (*dto.HandleFollowRequestResp, error) {
+
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Follow, event.Follow, event.Do, targetId, action.TargetType_USER, userId, func(ctx context.Context) (bool, error) {
+		if err := checkBlocked(ctx, service.BlockMongoMapper, targetId, action.TargetType_USER, userId); err != nil {
+			return false, err
+		}
+		return service.FollowMongoMapper.ApproveRequest(ctx, targetId, userId)
+	})
+
+	if errors.Is(err, consts.Blocked) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	if !ok {
+		return nil, consts.FollowRequestNotExist
+	}
+
+	return &dto.HandleFollowRequestResp{}, nil
+}
+
+func (service *FollowRequestService) RejectFollowRequest(ctx context.Context, targetId string, userId string) (*dto.HandleFollowRequestResp, error) {
+	ok, err := service.FollowMongoMapper.RejectRequest(ctx, targetId, userId)
+
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	if !ok {
+		return nil, consts.FollowRequestNotExist
+	}
+
+	return &dto.HandleFollowRequestResp{}, nil
+}
+
+func (service *FollowRequestService) WithdrawFollowRequest(ctx context.Context, targetId string, userId string) (*dto.WithdrawFollowRequestResp, error) {
+	ok, err := service.FollowMongoMapper.WithdrawRequest(ctx, targetId, userId)
+
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	if !ok {
+		return nil, consts.FollowRequestNotExist
+	}
+
+	return &dto.WithdrawFollowRequestResp{}, nil
+}
+
+func toFollowRequests(data []*follow.Follow) []*dto.FollowRequest {
+	requests := make([]*dto.FollowRequest, 0, len(data))
+	for _, val := range data {
+		requests = append(requests, &dto.FollowRequest{
+			Id:       val.ID.Hex(),
+			TargetId: val.TargetId,
+			UserId:   val.UserId,
+			CreateAt: val.CreateAt.Unix(),
+		})
+	}
+	return requests
+}
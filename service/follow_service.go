@@ -2,81 +2,146 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/jinzhu/copier"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"github.com/zeromicro/go-zero/core/mr"
+	"meowcloud-action/common/config"
 	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
 	"meowcloud-action/infra/mapper/follow"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/pagination"
+	"meowcloud-action/infra/mapper/privacy"
 )
 
 type IFollowService interface {
 	DoFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.DoFollowResp, error)
 	CancelFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.CancelFollowResp, error)
 	GetFollowedCount(ctx context.Context, targetId string, targetType action.TargetType) (*action.GetFollowedCountResp, error)
+	BatchGetFollowedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetFollowedCountResp, error)
 	GetFollowedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetFollowedUsersResp, error)
 	GetUserFollowed(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*action.GetUserFollowedResp, error)
 	GetFollowed(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.GetFollowedResp, error)
+	BatchGetFollowed(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetFollowedResp, error)
+	GetFollowedUsersWithMutual(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetFollowedUsersResp, error)
+	GetUserFollowedWithMutual(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserFollowedResp, error)
+	GetFollowedUsersWithToken(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetFollowedUsersResp, error)
+	GetUserFollowedWithToken(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserFollowedResp, error)
+	GetMutualFollowed(ctx context.Context, targetId string, userId string) (*dto.GetMutualFollowedResp, error)
+	GetMutualFollows(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetMutualFollowsResp, error)
+	GetFollowingNotFollowedBack(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetNotFollowedBackResp, error)
+	GetFollowersNotFollowedBack(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetNotFollowedBackResp, error)
+	GetCommonFollowers(ctx context.Context, targetId string, userId string, limit int64) (*dto.GetCommonFollowersResp, error)
 }
 
 type FollowService struct {
-	FollowMongoMapper follow.IMongoMapper
+	FollowMongoMapper  follow.IMongoMapper
+	CounterMongoMapper counter.IMongoMapper
+	EventMongoMapper   event.IMongoMapper
+	OutboxMongoMapper  outbox.IMongoMapper
+	BlockMongoMapper   block.IMongoMapper
+	PrivacyMongoMapper privacy.IMongoMapper
 }
 
 func NewFollowService() IFollowService {
 	mongoMapper := follow.NewMongoMapper()
 	return &FollowService{
-		FollowMongoMapper: mongoMapper,
+		FollowMongoMapper:  mongoMapper,
+		CounterMongoMapper: counter.NewMongoMapper(),
+		EventMongoMapper:   event.NewMongoMapper(),
+		OutboxMongoMapper:  outbox.NewMongoMapper(),
+		BlockMongoMapper:   block.NewMongoMapper(),
+		PrivacyMongoMapper: privacy.NewMongoMapper(),
 	}
 }
 
 func (service FollowService) DoFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.DoFollowResp, error) {
 
-	// 判断是否点赞过，不存在和未点赞都为true
-	followed, err := service.FollowMongoMapper.IsFollowed(ctx, targetId, targetType, userId)
+	if targetType == action.TargetType_USER {
+		// 私密用户需要本人同意，只创建待处理的关注请求
+		private, err := service.PrivacyMongoMapper.IsPrivate(ctx, targetId)
+		if err != nil {
+			return nil, err
+		}
+		if private {
+			// 通过请求时会在事务中再次校验拉黑
+			if err := checkBlocked(ctx, service.BlockMongoMapper, targetId, targetType, userId); err != nil {
+				return nil, err
+			}
+			return service.requestFollow(ctx, targetId, userId)
+		}
+	}
 
-	if err != nil {
+	// upsert是原子的，并发请求中只有一个能使关注生效
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Follow, event.Follow, event.Do, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		if err := checkBlocked(ctx, service.BlockMongoMapper, targetId, targetType, userId); err != nil {
+			return false, err
+		}
+		return service.FollowMongoMapper.InsertOne(ctx, targetId, targetType, userId)
+	})
+
+	if errors.Is(err, consts.Blocked) {
 		return nil, err
 	}
+	if err != nil {
+		return nil, consts.TryAgain
+	}
 
-	// 点赞过则抛出异常
-	if !followed {
+	// 关注过则抛出异常
+	if !ok {
 		return nil, consts.RepeatFollow
 	}
 
-	err = service.FollowMongoMapper.InsertOne(ctx, targetId, targetType, userId)
+	return &action.DoFollowResp{}, nil
+}
+
+func (service FollowService) requestFollow(ctx context.Context, targetId string, userId string) (*action.DoFollowResp, error) {
+	ok, err := service.FollowMongoMapper.RequestFollow(ctx, targetId, userId)
 
 	if err != nil {
 		return nil, consts.TryAgain
 	}
 
-	return &action.DoFollowResp{}, nil
-}
-
-func (service FollowService) CancelFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.CancelFollowResp, error) {
-
-	// 判断是否点赞过，不存在和未点赞都为true
-	followed, err := service.FollowMongoMapper.IsFollowed(ctx, targetId, targetType, userId)
+	if ok {
+		return &action.DoFollowResp{}, nil
+	}
 
+	// 区分已经关注和请求尚待处理
+	followed, err := service.FollowMongoMapper.IsFollowed(ctx, targetId, action.TargetType_USER, userId)
 	if err != nil {
 		return nil, err
 	}
-
-	// 未点赞过则抛出异常
 	if followed {
-		return nil, consts.FollowNotExist
+		return nil, consts.RepeatFollow
 	}
+	return nil, consts.RepeatFollowRequest
+}
+
+func (service FollowService) CancelFollow(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.CancelFollowResp, error) {
 
-	err = service.FollowMongoMapper.CancelFollow(ctx, targetId, targetType, userId)
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Follow, event.Follow, event.Cancel, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		return service.FollowMongoMapper.CancelFollow(ctx, targetId, targetType, userId)
+	})
 
 	if err != nil {
 		return nil, consts.TryAgain
 	}
 
+	// 未关注过则抛出异常
+	if !ok {
+		return nil, consts.FollowNotExist
+	}
+
 	return &action.CancelFollowResp{}, nil
 }
 
 func (service FollowService) GetFollowedCount(ctx context.Context, targetId string, targetType action.TargetType) (*action.GetFollowedCountResp, error) {
-	count, err := service.FollowMongoMapper.CountFollows(ctx, targetId, targetType)
+	count, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Follow, service.FollowMongoMapper.CountFollows)
 
 	if err != nil {
 		return nil, err
@@ -86,7 +151,14 @@ func (service FollowService) GetFollowedCount(ctx context.Context, targetId stri
 }
 
 func (service FollowService) GetFollowedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetFollowedUsersResp, error) {
-	data, total, err := service.FollowMongoMapper.GetFollowedUsers(ctx, targetId, targetType, options)
+	// IDL响应中没有token字段，只支持page/offset分页，需要游标分页时使用GetFollowedUsersWithToken
+	data, _, err := service.FollowMongoMapper.GetFollowedUsers(ctx, targetId, targetType, pagination.PageOnly(options))
+
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := service.countFollowers(ctx, targetId, targetType)
 
 	if err != nil {
 		return nil, err
@@ -111,7 +183,8 @@ func (service FollowService) GetFollowedUsers(ctx context.Context, targetId stri
 }
 
 func (service FollowService) GetUserFollowed(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*action.GetUserFollowedResp, error) {
-	data, total, err := service.FollowMongoMapper.GetUserFollowed(ctx, targetType, userId, options)
+	// IDL响应中没有token字段，只支持page/offset分页，需要游标分页时使用GetUserFollowedWithToken
+	data, total, _, err := service.FollowMongoMapper.GetUserFollowed(ctx, targetType, userId, pagination.PageOnly(options))
 
 	if err != nil {
 		return nil, err
@@ -144,3 +217,254 @@ func (service FollowService) GetFollowed(ctx context.Context, targetId string, t
 
 	return &action.GetFollowedResp{Followed: followed}, nil
 }
+
+func (service FollowService) BatchGetFollowed(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetFollowedResp, error) {
+	followed, err := service.FollowMongoMapper.BatchIsFollowed(ctx, targetIds, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetFollowedResp{Followed: followed}, nil
+}
+
+func (service FollowService) BatchGetFollowedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetFollowedCountResp, error) {
+	counts, err := batchGetCount(ctx, service.CounterMongoMapper, targetIds, targetType, counter.Follow, service.FollowMongoMapper.BatchCountFollows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetFollowedCountResp{Counts: counts}, nil
+}
+
+// GetFollowedUsersWithToken 与GetFollowedUsers一致，options中的LastToken按游标分页，响应中返回请求相邻页的token，不标记互相关注
+func (service FollowService) GetFollowedUsersWithToken(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetFollowedUsersResp, error) {
+	data, lastToken, err := service.FollowMongoMapper.GetFollowedUsers(ctx, targetId, targetType, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := service.countFollowers(ctx, targetId, targetType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetFollowedUsersResp{
+		Follows: toFollows(data, nil),
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// GetUserFollowedWithToken 与GetUserFollowed一致，options中的LastToken按游标分页，响应中返回请求相邻页的token，不标记互相关注
+func (service FollowService) GetUserFollowedWithToken(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserFollowedResp, error) {
+	data, total, lastToken, err := service.FollowMongoMapper.GetUserFollowed(ctx, targetType, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetUserFollowedResp{
+		Follows: toFollows(data, nil),
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// GetFollowedUsersWithMutual 在GetFollowedUsers的基础上标记目标用户是否回关了每个关注者
+func (service FollowService) GetFollowedUsersWithMutual(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetFollowedUsersResp, error) {
+	data, lastToken, err := service.FollowMongoMapper.GetFollowedUsers(ctx, targetId, targetType, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := service.countFollowers(ctx, targetId, targetType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mutual := map[string]bool{}
+	// 只有用户之间才存在互相关注
+	if targetType == action.TargetType_USER {
+		userIds := make([]string, 0, len(data))
+		for _, val := range data {
+			userIds = append(userIds, val.UserId)
+		}
+		mutual, err = service.FollowMongoMapper.BatchIsFollowed(ctx, userIds, action.TargetType_USER, targetId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.GetFollowedUsersResp{
+		Follows: toFollows(data, func(val *follow.Follow) bool { return mutual[val.UserId] }),
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// GetUserFollowedWithMutual 在GetUserFollowed的基础上标记每个被关注的用户是否回关了userId
+func (service FollowService) GetUserFollowedWithMutual(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserFollowedResp, error) {
+	data, total, lastToken, err := service.FollowMongoMapper.GetUserFollowed(ctx, targetType, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mutual := map[string]bool{}
+	// 只有用户之间才存在互相关注
+	if targetType == action.TargetType_USER {
+		targetIds := make([]string, 0, len(data))
+		for _, val := range data {
+			targetIds = append(targetIds, val.TargetId)
+		}
+		mutual, err = service.FollowMongoMapper.BatchIsFollowedBy(ctx, userId, action.TargetType_USER, targetIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.GetUserFollowedResp{
+		Follows: toFollows(data, func(val *follow.Follow) bool { return mutual[val.TargetId] }),
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+func (service FollowService) GetMutualFollowed(ctx context.Context, targetId string, userId string) (*dto.GetMutualFollowedResp, error) {
+	resp := &dto.GetMutualFollowedResp{}
+
+	err := mr.Finish(func() (err error) {
+		resp.Followed, err = service.FollowMongoMapper.IsFollowed(ctx, targetId, action.TargetType_USER, userId)
+		return err
+	}, func() (err error) {
+		resp.FollowedBack, err = service.FollowMongoMapper.IsFollowed(ctx, userId, action.TargetType_USER, targetId)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Mutual = resp.Followed && resp.FollowedBack
+
+	return resp, nil
+}
+
+func (service FollowService) GetMutualFollows(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetMutualFollowsResp, error) {
+	data, total, lastToken, err := service.FollowMongoMapper.GetMutualFollows(ctx, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetMutualFollowsResp{
+		Follows: toFollows(data, func(*follow.Follow) bool { return true }),
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// GetFollowingNotFollowedBack 当前用户关注了但没有回关当前用户的用户
+func (service FollowService) GetFollowingNotFollowedBack(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetNotFollowedBackResp, error) {
+	data, total, lastToken, err := service.FollowMongoMapper.GetFollowingNotFollowedBack(ctx, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetNotFollowedBackResp{
+		Follows: toFollows(data, func(*follow.Follow) bool { return false }),
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// GetFollowersNotFollowedBack 关注了当前用户但当前用户没有回关的用户
+func (service FollowService) GetFollowersNotFollowedBack(ctx context.Context, userId string, options *basic.PaginationOptions) (*dto.GetNotFollowedBackResp, error) {
+	data, total, lastToken, err := service.FollowMongoMapper.GetFollowersNotFollowedBack(ctx, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetNotFollowedBackResp{
+		Follows: toFollows(data, func(*follow.Follow) bool { return false }),
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// GetCommonFollowers 用于展示"你关注的N人也关注了TA"
+func (service FollowService) GetCommonFollowers(ctx context.Context, targetId string, userId string, limit int64) (*dto.GetCommonFollowersResp, error) {
+	if limit <= 0 {
+		limit = config.Get().Follow.CommonLimit
+	}
+	if limit > consts.MaxCommonFollowers {
+		limit = consts.MaxCommonFollowers
+	}
+
+	data, err := service.FollowMongoMapper.GetCommonFollowers(ctx, userId, targetId, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetCommonFollowersResp{
+		UserIds: data.UserIds,
+		Total:   data.Total,
+	}, nil
+}
+
+// countFollowers 关注者列表的总数，与列表一致，不包括与目标用户存在拉黑关系的关注者
+func (service FollowService) countFollowers(ctx context.Context, targetId string, targetType action.TargetType) (int64, error) {
+	total, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Follow, service.FollowMongoMapper.CountFollows)
+	if err != nil || targetType != action.TargetType_USER {
+		return total, err
+	}
+
+	blockedIds, err := service.BlockMongoMapper.GetEitherBlockedIds(ctx, targetId)
+	if err != nil || len(blockedIds) == 0 {
+		return total, err
+	}
+
+	followed, err := service.FollowMongoMapper.BatchIsFollowedBy(ctx, targetId, targetType, blockedIds)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, val := range followed {
+		if val {
+			total--
+		}
+	}
+	return total, nil
+}
+
+// toFollows mutual为nil时不标记互相关注
+func toFollows(data []*follow.Follow, mutual func(*follow.Follow) bool) []*dto.Follow {
+	follows := make([]*dto.Follow, 0, len(data))
+	for _, val := range data {
+		follows = append(follows, &dto.Follow{
+			Id:         val.ID.Hex(),
+			TargetId:   val.TargetId,
+			TargetType: val.TargetType,
+			UserId:     val.UserId,
+			CreateAt:   val.CreateAt.Unix(),
+			Mutual:     mutual != nil && mutual(val),
+		})
+	}
+	return follows
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"github.com/jinzhu/copier"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/pagination"
+)
+
+type IHistoryService interface {
+	GetUserHistory(ctx context.Context, userId string, act string, options *basic.PaginationOptions) (*dto.GetUserHistoryResp, error)
+	GetTargetHistory(ctx context.Context, targetId string, targetType action.TargetType, act string, options *basic.PaginationOptions) (*dto.GetTargetHistoryResp, error)
+}
+
+type HistoryService struct {
+	EventMongoMapper event.IMongoMapper
+}
+
+func NewHistoryService() IHistoryService {
+	mongoMapper := event.NewMongoMapper()
+	return &HistoryService{
+		EventMongoMapper: mongoMapper,
+	}
+}
+
+func (service *HistoryService) GetUserHistory(ctx context.Context, userId string, act string, options *basic.PaginationOptions) (*dto.GetUserHistoryResp, error) {
+	data, lastToken, err := service.EventMongoMapper.GetUserEvents(ctx, userId, event.Action(act), options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := toActionEvents(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetUserHistoryResp{
+		Events:  events,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+func (service *HistoryService) GetTargetHistory(ctx context.Context, targetId string, targetType action.TargetType, act string, options *basic.PaginationOptions) (*dto.GetTargetHistoryResp, error) {
+	data, lastToken, err := service.EventMongoMapper.GetTargetEvents(ctx, targetId, targetType, event.Action(act), options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := toActionEvents(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetTargetHistoryResp{
+		Events:  events,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+func toActionEvents(data []*event.Event) ([]*dto.ActionEvent, error) {
+	var events []*dto.ActionEvent
+	for _, val := range data {
+		aEvent := &dto.ActionEvent{}
+		err := copier.Copy(aEvent, val)
+		if err != nil {
+			return nil, err
+		}
+		aEvent.Id = val.ID.Hex()
+		aEvent.Action = string(val.Action)
+		aEvent.Op = string(val.Op)
+		aEvent.CreateAt = val.CreateAt.Unix()
+		events = append(events, aEvent)
+	}
+	return events, nil
+}
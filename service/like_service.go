@@ -2,136 +2,180 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/jinzhu/copier"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
 	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
 	"meowcloud-action/infra/mapper/like"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/pagination"
 )
 
 type ILikeService interface {
 	DoLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.DoLikeResp, error)
 	CancelLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.CancelLikeResp, error)
 	GetLikedCount(ctx context.Context, targetId string, targetType action.TargetType) (*action.GetLikedCountResp, error)
+	BatchGetLikedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetLikedCountResp, error)
 	GetLikedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetLikedUsersResp, error)
 	GetUserLiked(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*action.GetUserLikedResp, error)
 	GetLiked(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.GetLikedResp, error)
+	BatchGetLiked(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetLikedResp, error)
+	GetLikedUsersWithToken(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetLikedUsersResp, error)
+	GetUserLikedWithToken(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserLikedResp, error)
 }
 
 type LikeService struct {
-	LikeMongoMapper like.IMongoMapper
+	LikeMongoMapper    like.IMongoMapper
+	CounterMongoMapper counter.IMongoMapper
+	EventMongoMapper   event.IMongoMapper
+	OutboxMongoMapper  outbox.IMongoMapper
+	BlockMongoMapper   block.IMongoMapper
 }
 
 func NewLikeService() ILikeService {
 	mongoMapper := like.NewMongoMapper()
 	return &LikeService{
-		LikeMongoMapper: mongoMapper,
+		LikeMongoMapper:    mongoMapper,
+		CounterMongoMapper: counter.NewMongoMapper(),
+		EventMongoMapper:   event.NewMongoMapper(),
+		OutboxMongoMapper:  outbox.NewMongoMapper(),
+		BlockMongoMapper:   block.NewMongoMapper(),
 	}
 }
 
 func (service *LikeService) DoLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.DoLikeResp, error) {
 
-	// 判断是否点赞过，不存在和未点赞都为true
-	liked, err := service.LikeMongoMapper.IsLiked(ctx, targetId, targetType, userId)
+	// upsert是原子的，并发请求中只有一个能使点赞生效
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Like, event.Like, event.Do, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		if err := checkBlocked(ctx, service.BlockMongoMapper, targetId, targetType, userId); err != nil {
+			return false, err
+		}
+		return service.LikeMongoMapper.InsertOne(ctx, targetId, targetType, userId)
+	})
 
-	if err != nil {
+	if errors.Is(err, consts.Blocked) {
 		return nil, err
 	}
+	if err != nil {
+		return nil, consts.TryAgain
+	}
 
 	// 点赞过则抛出异常
-	if !liked {
+	if !ok {
 		return nil, consts.RepeatLike
 	}
 
-	err = service.LikeMongoMapper.InsertOne(ctx, targetId, targetType, userId)
+	return &action.DoLikeResp{}, nil
+}
+
+func (service *LikeService) CancelLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.CancelLikeResp, error) {
+
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Like, event.Like, event.Cancel, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		return service.LikeMongoMapper.CancelLike(ctx, targetId, targetType, userId)
+	})
 
 	if err != nil {
 		return nil, consts.TryAgain
 	}
 
-	return &action.DoLikeResp{}, nil
-}
+	// 未点赞过则抛出异常
+	if !ok {
+		return nil, consts.LikeNotExist
+	}
 
-func (service *LikeService) CancelLike(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.CancelLikeResp, error) {
+	return &action.CancelLikeResp{}, nil
+}
 
-	// 判断是否点赞过，不存在和未点赞都是true
-	liked, err := service.LikeMongoMapper.IsLiked(ctx, targetId, targetType, userId)
+func (service *LikeService) GetLikedCount(ctx context.Context, targetId string, targetType action.TargetType) (*action.GetLikedCountResp, error) {
+	count, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Like, service.LikeMongoMapper.CountLikes)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// 点赞过则抛出异常
-	if liked {
-		return nil, consts.LikeNotExist
-	}
+	return &action.GetLikedCountResp{Count: count}, nil
+}
 
-	err = service.LikeMongoMapper.CancelLike(ctx, targetId, targetType, userId)
+func (service *LikeService) GetLikedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetLikedUsersResp, error) {
+	// IDL响应中没有token字段，只支持page/offset分页，需要游标分页时使用GetLikedUsersWithToken
+	resp, err := service.GetLikedUsersWithToken(ctx, targetId, targetType, pagination.PageOnly(options))
 
 	if err != nil {
-		return nil, consts.TryAgain
+		return nil, err
 	}
 
-	return &action.CancelLikeResp{}, nil
+	return &action.GetLikedUsersResp{
+		Likes: resp.Likes,
+		Total: resp.Total,
+	}, nil
 }
 
-func (service *LikeService) GetLikedCount(ctx context.Context, targetId string, targetType action.TargetType) (*action.GetLikedCountResp, error) {
-	count, err := service.LikeMongoMapper.CountLikes(ctx, targetId, targetType)
+func (service *LikeService) GetUserLiked(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*action.GetUserLikedResp, error) {
+	// IDL响应中没有token字段，只支持page/offset分页，需要游标分页时使用GetUserLikedWithToken
+	resp, err := service.GetUserLikedWithToken(ctx, targetType, userId, pagination.PageOnly(options))
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &action.GetLikedCountResp{Count: count}, nil
+	return &action.GetUserLikedResp{
+		Likes: resp.Likes,
+		Total: resp.Total,
+	}, nil
 }
 
-func (service *LikeService) GetLikedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetLikedUsersResp, error) {
-	data, total, err := service.LikeMongoMapper.GetLikedUsers(ctx, targetId, targetType, options)
+// GetLikedUsersWithToken 与GetLikedUsers一致，options中的LastToken按游标分页，响应中返回请求相邻页的token
+func (service *LikeService) GetLikedUsersWithToken(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetLikedUsersResp, error) {
+	data, lastToken, err := service.LikeMongoMapper.GetLikedUsers(ctx, targetId, targetType, options)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var likes []*action.Action_Like
-	for _, val := range data {
-		aLike := &action.Action_Like{}
-		err := copier.Copy(aLike, val)
-		if err != nil {
-			return nil, err
-		}
-		aLike.Id = val.ID.Hex()
-		aLike.CreateAt = val.CreateAt.Unix()
-		likes = append(likes, aLike)
+	total, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Like, service.LikeMongoMapper.CountLikes)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return &action.GetLikedUsersResp{
-		Likes: likes,
-		Total: total,
+	likes, err := toLikes(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetLikedUsersResp{
+		Likes:   likes,
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
 	}, nil
 }
 
-func (service *LikeService) GetUserLiked(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*action.GetUserLikedResp, error) {
-	data, total, err := service.LikeMongoMapper.GetUserLiked(ctx, targetType, userId, options)
+// GetUserLikedWithToken 与GetUserLiked一致，options中的LastToken按游标分页，响应中返回请求相邻页的token
+func (service *LikeService) GetUserLikedWithToken(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserLikedResp, error) {
+	data, total, lastToken, err := service.LikeMongoMapper.GetUserLiked(ctx, targetType, userId, options)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var likes []*action.Action_Like
-	for _, val := range data {
-		aLike := &action.Action_Like{}
-		err := copier.Copy(aLike, val)
-		if err != nil {
-			return nil, err
-		}
-		aLike.Id = val.ID.Hex()
-		aLike.CreateAt = val.CreateAt.Unix()
-		likes = append(likes, aLike)
+	likes, err := toLikes(data)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return &action.GetUserLikedResp{
-		Likes: likes,
-		Total: total,
+	return &dto.GetUserLikedResp{
+		Likes:   likes,
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
 	}, nil
 }
 
@@ -144,3 +188,38 @@ func (service *LikeService) GetLiked(ctx context.Context, targetId string, targe
 
 	return &action.GetLikedResp{Liked: liked}, nil
 }
+
+func (service *LikeService) BatchGetLiked(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetLikedResp, error) {
+	liked, err := service.LikeMongoMapper.BatchIsLiked(ctx, targetIds, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetLikedResp{Liked: liked}, nil
+}
+
+func (service *LikeService) BatchGetLikedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetLikedCountResp, error) {
+	counts, err := batchGetCount(ctx, service.CounterMongoMapper, targetIds, targetType, counter.Like, service.LikeMongoMapper.BatchCountLikes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetLikedCountResp{Counts: counts}, nil
+}
+
+func toLikes(data []*like.Like) ([]*action.Action_Like, error) {
+	var likes []*action.Action_Like
+	for _, val := range data {
+		aLike := &action.Action_Like{}
+		err := copier.Copy(aLike, val)
+		if err != nil {
+			return nil, err
+		}
+		aLike.Id = val.ID.Hex()
+		aLike.CreateAt = val.CreateAt.Unix()
+		likes = append(likes, aLike)
+	}
+	return likes, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/mongo"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/outbox"
+)
+
+type changeFunc func(ctx context.Context) (bool, error)
+
+// 事务中的唯一索引冲突会中止事务，无法在事务内重试，只能重试整个事务
+const maxOutboxRetries = 3
+
+// withOutbox 在同一个事务中执行状态变更、写入outbox消息和行为历史并更新kind计数，change返回false表示状态未变化，此时不产生消息，
+// kind为空表示该行为没有计数。并发upsert或生成的分享码冲突时重试整个事务，change需要可重复执行
+func withOutbox(ctx context.Context, outboxMapper outbox.IMongoMapper, counterMapper counter.IMongoMapper, eventMapper event.IMongoMapper, kind counter.Kind, act event.Action, op event.Op, targetId string, targetType action.TargetType, userId string, change changeFunc) (bool, error) {
+	var changed bool
+	var err error
+	for i := 0; i < maxOutboxRetries; i++ {
+		err = outboxMapper.Transaction(ctx, func(ctx context.Context) error {
+			var err error
+			changed, err = change(ctx)
+			if err != nil || !changed {
+				return err
+			}
+			if err = outboxMapper.InsertOne(ctx, act, op, targetId, targetType, userId); err != nil {
+				return err
+			}
+			if err = eventMapper.InsertOne(ctx, act, op, targetId, targetType, userId); err != nil || kind == "" {
+				return err
+			}
+			return counterMapper.Incr(ctx, targetId, targetType, kind, countDelta(op))
+		})
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	return changed, err
+}
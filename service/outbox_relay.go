@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xh-polaris/gopkg/util/log"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mq"
+	"time"
+)
+
+// DomainEvent 投递到消息队列的消息体，消费方应使用Id去重
+type DomainEvent struct {
+	Id    string      `json:"id"`
+	Topic string      `json:"topic"`
+	Event event.Event `json:"event"`
+}
+
+// OutboxRelay 定期领取outbox中待投递的消息发送到各下游，全部成功后标记为已投递，保证至少投递一次
+type OutboxRelay struct {
+	OutboxMongoMapper outbox.IMongoMapper
+	Sinks             []mq.Sink
+	batchSize         int64
+	interval          time.Duration
+	lease             time.Duration
+	maxAttempts       int64
+	done              chan struct{}
+}
+
+func NewOutboxRelay(sinks ...mq.Sink) *OutboxRelay {
+	aConfig := config.Get()
+	return &OutboxRelay{
+		OutboxMongoMapper: outbox.NewMongoMapper(),
+		Sinks:             sinks,
+		batchSize:         aConfig.Outbox.BatchSize,
+		interval:          aConfig.Outbox.Interval,
+		lease:             aConfig.Outbox.Lease,
+		maxAttempts:       aConfig.Outbox.MaxAttempts,
+		done:              make(chan struct{}),
+	}
+}
+
+// Start 阻塞运行直到Stop被调用
+func (r *OutboxRelay) Start() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.relay(context.Background())
+		}
+	}
+}
+
+func (r *OutboxRelay) Stop() {
+	close(r.done)
+}
+
+func (r *OutboxRelay) relay(ctx context.Context) {
+	for i := int64(0); i < r.batchSize; i++ {
+		message, err := r.OutboxMongoMapper.ClaimPending(ctx, r.lease)
+		if err != nil {
+			log.CtxError(ctx, "领取outbox消息失败: %v", err)
+			return
+		}
+		// 没有待投递的消息
+		if message == nil {
+			return
+		}
+		r.deliver(ctx, message)
+	}
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, message *outbox.Message) {
+	payload, err := json.Marshal(&DomainEvent{
+		Id:    message.ID.Hex(),
+		Topic: message.Topic(),
+		Event: message.Event,
+	})
+	if err == nil {
+		err = r.publish(ctx, message, payload)
+	}
+
+	if err != nil {
+		log.CtxError(ctx, "投递outbox消息%s失败: %v", message.ID.Hex(), err)
+		// attempts在领取时已加一
+		if message.Attempts >= r.maxAttempts {
+			if err = r.OutboxMongoMapper.MarkDead(ctx, message.ID, err); err != nil {
+				log.CtxError(ctx, "outbox消息%s转入死信失败: %v", message.ID.Hex(), err)
+			}
+			return
+		}
+		if err = r.OutboxMongoMapper.MarkFailed(ctx, message.ID, err); err != nil {
+			log.CtxError(ctx, "记录outbox消息%s失败原因失败: %v", message.ID.Hex(), err)
+		}
+		return
+	}
+
+	// 标记失败时锁过期后会再次投递，消费方需要幂等
+	if err = r.OutboxMongoMapper.MarkDelivered(ctx, message.ID); err != nil {
+		log.CtxError(ctx, "标记outbox消息%s已投递失败: %v", message.ID.Hex(), err)
+	}
+}
+
+// publish 依次投递到尚未成功的下游，每个下游成功后立即记录，重试时不会重复投递到已成功的下游
+func (r *OutboxRelay) publish(ctx context.Context, message *outbox.Message, payload []byte) error {
+	for _, sink := range r.Sinks {
+		if message.IsDeliveredTo(sink.Name) {
+			continue
+		}
+		if err := sink.Publisher.Publish(ctx, message.Topic(), payload); err != nil {
+			return fmt.Errorf("%s: %w", sink.Name, err)
+		}
+		// 记录失败时该下游会被重复投递，消费方需要幂等
+		if err := r.OutboxMongoMapper.MarkSinkDelivered(ctx, message.ID, sink.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
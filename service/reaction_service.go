@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/config"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/like"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/pagination"
+)
+
+type IReactionService interface {
+	DoReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string, reaction string) (*dto.DoReactionResp, error)
+	CancelReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.CancelReactionResp, error)
+	GetReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.GetReactionResp, error)
+	GetReactionCounts(ctx context.Context, targetId string, targetType action.TargetType) (*dto.GetReactionCountsResp, error)
+	GetReactedUsers(ctx context.Context, targetId string, targetType action.TargetType, reaction string, options *basic.PaginationOptions) (*dto.GetReactedUsersResp, error)
+	GetReactionOptions(ctx context.Context) (*dto.GetReactionOptionsResp, error)
+}
+
+// ReactionService 表情保存在like记录上，使用任意表情都算一次点赞，GetLikedCount等接口不受影响
+type ReactionService struct {
+	LikeMongoMapper    like.IMongoMapper
+	CounterMongoMapper counter.IMongoMapper
+	EventMongoMapper   event.IMongoMapper
+	OutboxMongoMapper  outbox.IMongoMapper
+	BlockMongoMapper   block.IMongoMapper
+	// 取消表情即取消点赞
+	LikeService ILikeService
+
+	defaultReaction string
+	options         []string
+}
+
+func NewReactionService() IReactionService {
+	aConfig := config.Get()
+
+	options := []string{aConfig.Reaction.Default}
+	for _, val := range aConfig.Reaction.Options {
+		if val != aConfig.Reaction.Default {
+			options = append(options, val)
+		}
+	}
+
+	return &ReactionService{
+		LikeMongoMapper:    like.NewMongoMapper(),
+		CounterMongoMapper: counter.NewMongoMapper(),
+		EventMongoMapper:   event.NewMongoMapper(),
+		OutboxMongoMapper:  outbox.NewMongoMapper(),
+		BlockMongoMapper:   block.NewMongoMapper(),
+		LikeService:        NewLikeService(),
+		defaultReaction:    aConfig.Reaction.Default,
+		options:            options,
+	}
+}
+
+// checkReaction 返回实际使用的表情，为空时使用默认表情
+func (service *ReactionService) checkReaction(reaction string) (string, error) {
+	if reaction == "" {
+		return service.defaultReaction, nil
+	}
+	for _, val := range service.options {
+		if val == reaction {
+			return reaction, nil
+		}
+	}
+	return "", consts.ReactionNotSupport
+}
+
+func (service *ReactionService) DoReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string, reaction string) (*dto.DoReactionResp, error) {
+
+	reaction, err := service.checkReaction(reaction)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只有新增点赞时才产生消息，替换表情不改变点赞数
+	var old *like.Like
+	ok, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Like, event.Like, event.Do, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		if err := checkBlocked(ctx, service.BlockMongoMapper, targetId, targetType, userId); err != nil {
+			return false, err
+		}
+		var err error
+		old, err = service.LikeMongoMapper.React(ctx, targetId, targetType, userId, reaction)
+		return err == nil && (old == nil || old.IsCancel), err
+	})
+
+	if errors.Is(err, consts.Blocked) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, consts.TryAgain
+	}
+
+	if ok {
+		return &dto.DoReactionResp{}, nil
+	}
+
+	// 使用相同的表情则抛出异常
+	if old.Reaction == reaction {
+		return nil, consts.RepeatReaction
+	}
+
+	return &dto.DoReactionResp{Previous: old.Reaction}, nil
+}
+
+func (service *ReactionService) CancelReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.CancelReactionResp, error) {
+	_, err := service.LikeService.CancelLike(ctx, targetId, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CancelReactionResp{}, nil
+}
+
+func (service *ReactionService) GetReaction(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*dto.GetReactionResp, error) {
+	reaction, err := service.LikeMongoMapper.GetReaction(ctx, targetId, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetReactionResp{Reaction: reaction}, nil
+}
+
+// GetReactionCounts 不在可选范围内的历史表情也会返回
+func (service *ReactionService) GetReactionCounts(ctx context.Context, targetId string, targetType action.TargetType) (*dto.GetReactionCountsResp, error) {
+	data, err := service.LikeMongoMapper.CountReactions(ctx, targetId, targetType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(service.options))
+	for _, val := range service.options {
+		counts[val] = 0
+	}
+
+	var total int64
+	for reaction, val := range data {
+		counts[reaction] += val
+		total += val
+	}
+
+	return &dto.GetReactionCountsResp{
+		Counts: counts,
+		Total:  total,
+	}, nil
+}
+
+func (service *ReactionService) GetReactedUsers(ctx context.Context, targetId string, targetType action.TargetType, reaction string, options *basic.PaginationOptions) (*dto.GetReactedUsersResp, error) {
+	if reaction == "" {
+		reaction = service.defaultReaction
+	}
+
+	data, lastToken, err := service.LikeMongoMapper.GetReactedUsers(ctx, targetId, targetType, reaction, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := service.LikeMongoMapper.CountReactedUsers(ctx, targetId, targetType, reaction)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reactions := make([]*dto.Reaction, 0, len(data))
+	for _, val := range data {
+		reactions = append(reactions, &dto.Reaction{
+			Id:         val.ID.Hex(),
+			TargetId:   val.TargetId,
+			TargetType: val.TargetType,
+			UserId:     val.UserId,
+			Reaction:   val.Reaction,
+			CreateAt:   val.CreateAt.Unix(),
+		})
+	}
+
+	return &dto.GetReactedUsersResp{
+		Reactions: reactions,
+		Total:     total,
+		Token:     lastToken,
+		HasMore:   pagination.HasMore(lastToken),
+	}, nil
+}
+
+func (service *ReactionService) GetReactionOptions(ctx context.Context) (*dto.GetReactionOptionsResp, error) {
+	return &dto.GetReactionOptionsResp{
+		Default: service.defaultReaction,
+		Options: service.options,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/config"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/follow"
+	"meowcloud-action/infra/mapper/recommend"
+	"time"
+)
+
+type IRecommendService interface {
+	GetFollowRecommendations(ctx context.Context, targetType action.TargetType, userId string, limit int64) (*dto.GetFollowRecommendationsResp, error)
+	PrecomputeFollowRecommendations(ctx context.Context, targetType action.TargetType, userId string) error
+	GetHeavyUsers(ctx context.Context) ([]string, error)
+}
+
+type RecommendService struct {
+	FollowMongoMapper    follow.IMongoMapper
+	RecommendMongoMapper recommend.IMongoMapper
+	BlockMongoMapper     block.IMongoMapper
+}
+
+func NewRecommendService() IRecommendService {
+	return &RecommendService{
+		FollowMongoMapper:    follow.NewMongoMapper(),
+		RecommendMongoMapper: recommend.NewMongoMapper(),
+		BlockMongoMapper:     block.NewMongoMapper(),
+	}
+}
+
+// GetFollowRecommendations 优先使用未过期的离线预计算结果，否则在线聚合
+func (service *RecommendService) GetFollowRecommendations(ctx context.Context, targetType action.TargetType, userId string, limit int64) (*dto.GetFollowRecommendationsResp, error) {
+	aConfig := config.Get()
+	if limit <= 0 {
+		limit = aConfig.Recommend.Limit
+	}
+	if limit > consts.MaxRecommendations {
+		limit = consts.MaxRecommendations
+	}
+
+	precomputed, err := service.RecommendMongoMapper.FindOne(ctx, userId, targetType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if precomputed != nil && time.Since(precomputed.UpdateAt) < aConfig.Recommend.TTL {
+		candidates, err := service.excludeFollowed(ctx, targetType, userId, precomputed.Candidates)
+		if err != nil {
+			return nil, err
+		}
+		return toRecommendations(candidates, limit, true), nil
+	}
+
+	candidates, err := service.FollowMongoMapper.GetFriendsOfFriends(ctx, userId, targetType, aConfig.Recommend.SampleSize, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toRecommendations(candidates, limit, false), nil
+}
+
+// PrecomputeFollowRecommendations 计算并保存用户的推荐结果，供离线任务调用
+func (service *RecommendService) PrecomputeFollowRecommendations(ctx context.Context, targetType action.TargetType, userId string) error {
+	candidates, err := service.FollowMongoMapper.GetFriendsOfFriends(ctx, userId, targetType, config.Get().Recommend.SampleSize, consts.MaxRecommendations)
+
+	if err != nil {
+		return err
+	}
+
+	return service.RecommendMongoMapper.Upsert(ctx, userId, targetType, candidates)
+}
+
+// GetHeavyUsers 关注数较多、在线计算代价较高的用户
+func (service *RecommendService) GetHeavyUsers(ctx context.Context) ([]string, error) {
+	return service.FollowMongoMapper.GetHeavyUsers(ctx, config.Get().Recommend.HeavyFollowing)
+}
+
+// excludeFollowed 去掉预计算之后用户已经关注的对象，以及与用户存在拉黑关系的用户
+func (service *RecommendService) excludeFollowed(ctx context.Context, targetType action.TargetType, userId string, candidates []*recommend.Candidate) ([]*recommend.Candidate, error) {
+	targetIds := make([]string, 0, len(candidates))
+	for _, val := range candidates {
+		targetIds = append(targetIds, val.TargetId)
+	}
+
+	followed, err := service.FollowMongoMapper.BatchIsFollowed(ctx, targetIds, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blocked := map[string]bool{}
+	if targetType == action.TargetType_USER {
+		blockedIds, err := service.BlockMongoMapper.GetEitherBlockedIds(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range blockedIds {
+			blocked[val] = true
+		}
+	}
+
+	result := make([]*recommend.Candidate, 0, len(candidates))
+	for _, val := range candidates {
+		if !followed[val.TargetId] && !blocked[val.TargetId] && val.TargetId != userId {
+			result = append(result, val)
+		}
+	}
+
+	return result, nil
+}
+
+func toRecommendations(candidates []*recommend.Candidate, limit int64, precomputed bool) *dto.GetFollowRecommendationsResp {
+	if int64(len(candidates)) > limit {
+		candidates = candidates[:limit]
+	}
+
+	recommendations := make([]*dto.Recommendation, 0, len(candidates))
+	for _, val := range candidates {
+		recommendations = append(recommendations, &dto.Recommendation{
+			TargetId: val.TargetId,
+			Score:    val.Score,
+		})
+	}
+
+	return &dto.GetFollowRecommendationsResp{
+		Recommendations: recommendations,
+		Precomputed:     precomputed,
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/xh-polaris/gopkg/util/log"
+	"meowcloud-action/common/config"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/idempotency"
+	"meowcloud-action/infra/mapper/conversion"
+	"meowcloud-action/infra/mapper/like"
+	"meowcloud-action/infra/mapper/share"
+	"meowcloud-action/infra/ratelimit"
+)
+
+// 访问去重的作用域
+const idempotencyScopeVisit = "share_visit"
+
+type IShareLinkService interface {
+	ResolveShareCode(ctx context.Context, code string, userId string) (*dto.ResolveShareCodeResp, error)
+	CreditShareConversion(ctx context.Context, code string, kind string, userId string) (*dto.CreditShareConversionResp, error)
+	RecordShareSignUp(ctx context.Context, code string, userId string) (*dto.RecordShareSignUpResp, error)
+	GetShareStats(ctx context.Context, code string, userId string) (*dto.GetShareStatsResp, error)
+	GetUserShareStats(ctx context.Context, userId string) (*dto.GetUserShareStatsResp, error)
+}
+
+type ShareLinkService struct {
+	ShareMongoMapper      share.IMongoMapper
+	ConversionMongoMapper conversion.IMongoMapper
+	LikeMongoMapper       like.IMongoMapper
+	// 登录用户的访问在窗口内去重，匿名访问按分享码限流
+	VisitStore   *idempotency.Store
+	VisitLimiter *ratelimit.Limiter
+}
+
+func NewShareLinkService() IShareLinkService {
+	return &ShareLinkService{
+		ShareMongoMapper:      share.NewMongoMapper(),
+		ConversionMongoMapper: conversion.NewMongoMapper(),
+		LikeMongoMapper:       like.NewMongoMapper(),
+		VisitStore:            idempotency.NewStore(config.Get().Share.VisitWindow),
+		VisitLimiter:          ratelimit.NewLimiter(),
+	}
+}
+
+// statsField 转化类型对应share.Stats中的字段
+var statsField = map[conversion.Kind]string{
+	conversion.Visit:  "visits",
+	conversion.SignUp: "sign_ups",
+	conversion.Like:   "likes",
+}
+
+// ResolveShareCode 访问只计入分享的统计，不逐条记录。登录用户在窗口内重复打开只计一次，匿名访问超过限流后不再计入
+func (service *ShareLinkService) ResolveShareCode(ctx context.Context, code string, userId string) (*dto.ResolveShareCodeResp, error) {
+	data, err := service.findShare(ctx, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if service.isNewVisit(ctx, data, userId) {
+		err = service.ShareMongoMapper.IncrStats(ctx, data.ID, statsField[conversion.Visit])
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	channel := data.Channel
+	if channel == "" {
+		channel = consts.ShareChannelUnknown
+	}
+
+	return &dto.ResolveShareCodeResp{
+		ShareId:    data.ID.Hex(),
+		TargetId:   data.TargetId,
+		TargetType: data.TargetType,
+		SharerId:   data.UserId,
+		Channel:    channel,
+	}, nil
+}
+
+// isNewVisit 访问统计只是参考，redis故障时照常计入
+func (service *ShareLinkService) isNewVisit(ctx context.Context, data *share.Share, userId string) bool {
+	if userId == "" {
+		return service.VisitLimiter.AllowKey(ctx, ratelimit.ShareVisit, data.Code)
+	}
+
+	_, acquired, err := service.VisitStore.Acquire(ctx, idempotencyScopeVisit, userId, data.ID.Hex())
+	if err != nil {
+		log.CtxError(ctx, "检查分享访问去重失败，照常计入: %v", err)
+		return true
+	}
+	return acquired
+}
+
+// CreditShareConversion 点赞在每次分享中只计入一次且需要确实点赞了分享的目标。
+// 注册无法由客户端证明，只能由账号服务通过RecordShareSignUp计入
+func (service *ShareLinkService) CreditShareConversion(ctx context.Context, code string, kind string, userId string) (*dto.CreditShareConversionResp, error) {
+	if conversion.Kind(kind) != conversion.Like {
+		return nil, consts.ConversionNotSupport
+	}
+
+	data, err := service.findShare(ctx, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	liked, err := service.LikeMongoMapper.IsLiked(ctx, data.TargetId, data.TargetType, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !liked {
+		return nil, consts.LikeNotExist
+	}
+
+	credited, err := service.credit(ctx, data, conversion.Like, userId, kind+":"+data.ID.Hex()+":"+userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreditShareConversionResp{Credited: credited}, nil
+}
+
+// RecordShareSignUp 由账号服务在用户通过分享链接注册后调用，注册在全部分享中只计入一次
+func (service *ShareLinkService) RecordShareSignUp(ctx context.Context, code string, userId string) (*dto.RecordShareSignUpResp, error) {
+	data, err := service.findShare(ctx, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credited, err := service.credit(ctx, data, conversion.SignUp, userId, string(conversion.SignUp)+":"+userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RecordShareSignUpResp{Credited: credited}, nil
+}
+
+// errConversionExists 转化已经记录过，唯一索引冲突会中止事务，用它结束事务
+var errConversionExists = errors.New("转化已经记录过")
+
+// credit 在同一事务中记录一次转化并计入分享的统计，统计失败时转化记录一起回滚，重试时仍可计入。
+// dedupKey重复或分享者本人时返回false
+func (service *ShareLinkService) credit(ctx context.Context, data *share.Share, kind conversion.Kind, userId string, dedupKey string) (bool, error) {
+	// 分享者自己的行为不算转化
+	if userId == data.UserId {
+		return false, nil
+	}
+
+	err := service.ConversionMongoMapper.Transaction(ctx, func(ctx context.Context) error {
+		ok, err := service.ConversionMongoMapper.InsertOne(ctx, &conversion.Conversion{
+			ShareId:  data.ID.Hex(),
+			SharerId: data.UserId,
+			Kind:     kind,
+			UserId:   userId,
+			DedupKey: dedupKey,
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errConversionExists
+		}
+		return service.ShareMongoMapper.IncrStats(ctx, data.ID, statsField[kind])
+	})
+
+	switch {
+	case errors.Is(err, errConversionExists):
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// GetShareStats 只有分享者本人可以查看
+func (service *ShareLinkService) GetShareStats(ctx context.Context, code string, userId string) (*dto.GetShareStatsResp, error) {
+	data, err := service.findShare(ctx, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data.UserId != userId {
+		return nil, consts.ShareCodeNotExist
+	}
+
+	return &dto.GetShareStatsResp{
+		Stats: &dto.ShareStats{
+			Visits:  data.Stats.Visits,
+			SignUps: data.Stats.SignUps,
+			Likes:   data.Stats.Likes,
+		},
+	}, nil
+}
+
+func (service *ShareLinkService) GetUserShareStats(ctx context.Context, userId string) (*dto.GetUserShareStatsResp, error) {
+	data, err := service.ShareMongoMapper.GetUserStats(ctx, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetUserShareStatsResp{
+		Shares: data.Shares,
+		Stats: &dto.ShareStats{
+			Visits:  data.Visits,
+			SignUps: data.SignUps,
+			Likes:   data.Likes,
+		},
+	}, nil
+}
+
+func (service *ShareLinkService) findShare(ctx context.Context, code string) (*share.Share, error) {
+	if code == "" {
+		return nil, consts.ShareCodeNotExist
+	}
+
+	data, err := service.ShareMongoMapper.FindByCode(ctx, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, consts.ShareCodeNotExist
+	}
+
+	return data, nil
+}
@@ -2,45 +2,115 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/jinzhu/copier"
+	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
 	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/config"
 	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/idempotency"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/pagination"
 	"meowcloud-action/infra/mapper/share"
+	"strings"
 )
 
+// 幂等键的作用域
+const idempotencyScopeShare = "share"
+
 type IShareService interface {
-	DoShare(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.DoShareResp, error)
+	DoShare(ctx context.Context, targetId string, targetType action.TargetType, userId string, channel string, metadata map[string]string, idempotencyKey string) (*dto.DoShareResp, error)
 	GetSharedCount(ctx context.Context, targetId string, targetType action.TargetType) (*action.GetSharedCountResp, error)
+	GetSharedCountBreakdown(ctx context.Context, targetId string, targetType action.TargetType) (*dto.GetSharedCountBreakdownResp, error)
+	BatchGetSharedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetSharedCountResp, error)
 	GetSharedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetSharedUsersResp, error)
 	GetUserShared(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*action.GetUserSharedResp, error)
 	GetShared(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.GetSharedResp, error)
+	BatchGetShared(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetSharedResp, error)
+	GetSharedUsersWithToken(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetSharedUsersResp, error)
+	GetUserSharedWithToken(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserSharedResp, error)
 }
 
 type ShareService struct {
-	ShareMongoMapper share.IMongoMapper
+	ShareMongoMapper   share.IMongoMapper
+	CounterMongoMapper counter.IMongoMapper
+	EventMongoMapper   event.IMongoMapper
+	OutboxMongoMapper  outbox.IMongoMapper
+	BlockMongoMapper   block.IMongoMapper
+	IdempotencyStore   *idempotency.Store
 }
 
 func NewShareService() *ShareService {
 	mongoMapper := share.NewMongoMapper()
 	return &ShareService{
-		ShareMongoMapper: mongoMapper,
+		ShareMongoMapper:   mongoMapper,
+		CounterMongoMapper: counter.NewMongoMapper(),
+		EventMongoMapper:   event.NewMongoMapper(),
+		OutboxMongoMapper:  outbox.NewMongoMapper(),
+		BlockMongoMapper:   block.NewMongoMapper(),
+		IdempotencyStore:   idempotency.NewStore(config.Get().Share.IdempotencyWindow),
 	}
 }
 
-func (service ShareService) DoShare(ctx context.Context, targetId string, targetType action.TargetType, userId string) (*action.DoShareResp, error) {
+// DoShare idempotencyKey为空时每次调用都记录一次分享，否则窗口期内同一个键只记录一次，重复提交返回第一次的结果
+func (service ShareService) DoShare(ctx context.Context, targetId string, targetType action.TargetType, userId string, channel string, metadata map[string]string, idempotencyKey string) (*dto.DoShareResp, error) {
+
+	if idempotencyKey != "" {
+		result, acquired, err := service.IdempotencyStore.Acquire(ctx, idempotencyScopeShare, userId, idempotencyKey)
+		switch {
+		case err != nil:
+			// 幂等只是防止重复计数，redis故障时按普通分享处理
+			log.CtxError(ctx, "检查分享幂等键失败，按普通分享处理: %v", err)
+			idempotencyKey = ""
+		case !acquired && result == "":
+			// 第一次请求还在处理中
+			return nil, consts.TryAgain
+		case !acquired:
+			shareId, code, _ := strings.Cut(result, ":")
+			return &dto.DoShareResp{ShareId: shareId, Code: code, Replayed: true}, nil
+		}
+	}
 
-	err := service.ShareMongoMapper.InsertOne(ctx, targetId, targetType, userId)
+	var newShare *share.Share
+	_, err := withOutbox(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, service.EventMongoMapper, counter.Share, event.Share, event.Do, targetId, targetType, userId, func(ctx context.Context) (bool, error) {
+		if err := checkBlocked(ctx, service.BlockMongoMapper, targetId, targetType, userId); err != nil {
+			return false, err
+		}
+		var err error
+		newShare, err = service.ShareMongoMapper.InsertOne(ctx, targetId, targetType, userId, channel, metadata)
+		return err == nil, err
+	})
 
 	if err != nil {
+		if idempotencyKey != "" {
+			if err := service.IdempotencyStore.Release(ctx, idempotencyScopeShare, userId, idempotencyKey); err != nil {
+				log.CtxError(ctx, "释放分享幂等键失败: %v", err)
+			}
+		}
+		if errors.Is(err, consts.Blocked) {
+			return nil, err
+		}
 		return nil, consts.TryAgain
 	}
 
-	return &action.DoShareResp{}, nil
+	if idempotencyKey != "" {
+		if err := service.IdempotencyStore.Save(ctx, idempotencyScopeShare, userId, idempotencyKey, newShare.ID.Hex()+":"+newShare.Code); err != nil {
+			log.CtxError(ctx, "保存分享幂等结果失败: %v", err)
+		}
+	}
+
+	return &dto.DoShareResp{ShareId: newShare.ID.Hex(), Code: newShare.Code}, nil
 }
 
+// GetSharedCount action.GetSharedCountResp由IDL生成，只能返回总数，
+// 各渠道的数量和分享过的不同用户数由GetSharedCountBreakdown返回
 func (service ShareService) GetSharedCount(ctx context.Context, targetId string, targetType action.TargetType) (*action.GetSharedCountResp, error) {
-	count, err := service.ShareMongoMapper.CountShares(ctx, targetId, targetType)
+	count, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Share, service.ShareMongoMapper.CountShares)
 
 	if err != nil {
 		return nil, err
@@ -49,53 +119,116 @@ func (service ShareService) GetSharedCount(ctx context.Context, targetId string,
 	return &action.GetSharedCountResp{Count: count}, nil
 }
 
-func (service ShareService) GetSharedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetSharedUsersResp, error) {
-	data, total, err := service.ShareMongoMapper.GetSharedUsers(ctx, targetId, targetType, options)
+// GetSharedCountBreakdown 是GetSharedCount的dto版本，各渠道的数量直接从share集合聚合，没有渠道的历史记录计入unknown
+func (service ShareService) GetSharedCountBreakdown(ctx context.Context, targetId string, targetType action.TargetType) (*dto.GetSharedCountBreakdownResp, error) {
+	count, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Share, service.ShareMongoMapper.CountShares)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var shares []*action.Action_Share
-	for _, val := range data {
-		aShare := &action.Action_Share{}
-		err := copier.Copy(aShare, val)
-		if err != nil {
-			return nil, err
+	sharers, err := service.ShareMongoMapper.CountSharers(ctx, targetId, targetType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := service.ShareMongoMapper.CountSharesByChannel(ctx, targetId, targetType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make(map[string]int64, len(data))
+	for channel, val := range data {
+		if channel == "" {
+			channel = consts.ShareChannelUnknown
 		}
-		aShare.Id = val.ID.Hex()
-		aShare.CreateAt = val.CreateAt.Unix()
-		shares = append(shares, aShare)
+		channels[channel] += val
+	}
+
+	return &dto.GetSharedCountBreakdownResp{
+		Count:    count,
+		Sharers:  sharers,
+		Channels: channels,
+	}, nil
+}
+
+func (service ShareService) GetSharedUsers(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*action.GetSharedUsersResp, error) {
+	// IDL响应中没有token字段，只支持page/offset分页，需要游标分页时使用GetSharedUsersWithToken
+	resp, err := service.GetSharedUsersWithToken(ctx, targetId, targetType, pagination.PageOnly(options))
+
+	if err != nil {
+		return nil, err
 	}
 
 	return &action.GetSharedUsersResp{
-		Shares: shares,
-		Total:  total,
+		Shares: resp.Shares,
+		Total:  resp.Total,
 	}, nil
 }
 
 func (service ShareService) GetUserShared(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*action.GetUserSharedResp, error) {
-	data, total, err := service.ShareMongoMapper.GetUserShared(ctx, targetType, userId, options)
+	// IDL响应中没有token字段，只支持page/offset分页，需要游标分页时使用GetUserSharedWithToken
+	resp, err := service.GetUserSharedWithToken(ctx, targetType, userId, pagination.PageOnly(options))
 
 	if err != nil {
 		return nil, err
 	}
 
-	var shares []*action.Action_Share
-	for _, val := range data {
-		aShare := &action.Action_Share{}
-		err := copier.Copy(aShare, val)
-		if err != nil {
-			return nil, err
-		}
-		aShare.Id = val.ID.Hex()
-		aShare.CreateAt = val.CreateAt.Unix()
-		shares = append(shares, aShare)
+	return &action.GetUserSharedResp{
+		Shares: resp.Shares,
+		Total:  resp.Total,
+	}, nil
+}
+
+// GetSharedUsersWithToken 与GetSharedUsers一致，options中的LastToken按游标分页，响应中返回请求相邻页的token
+func (service ShareService) GetSharedUsersWithToken(ctx context.Context, targetId string, targetType action.TargetType, options *basic.PaginationOptions) (*dto.GetSharedUsersResp, error) {
+	data, lastToken, err := service.ShareMongoMapper.GetSharedUsers(ctx, targetId, targetType, options)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return &action.GetUserSharedResp{
-		Shares: shares,
-		Total:  total,
+	total, err := getCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Share, service.ShareMongoMapper.CountShares)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := toShares(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetSharedUsersResp{
+		Shares:  shares,
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
+	}, nil
+}
+
+// GetUserSharedWithToken 与GetUserShared一致，options中的LastToken按游标分页，响应中返回请求相邻页的token
+func (service ShareService) GetUserSharedWithToken(ctx context.Context, targetType action.TargetType, userId string, options *basic.PaginationOptions) (*dto.GetUserSharedResp, error) {
+	data, total, lastToken, err := service.ShareMongoMapper.GetUserShared(ctx, targetType, userId, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := toShares(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GetUserSharedResp{
+		Shares:  shares,
+		Total:   total,
+		Token:   lastToken,
+		HasMore: pagination.HasMore(lastToken),
 	}, nil
 }
 
@@ -108,3 +241,44 @@ func (service ShareService) GetShared(ctx context.Context, targetId string, targ
 
 	return &action.GetSharedResp{Shared: shared}, nil
 }
+
+func (service ShareService) BatchGetShared(ctx context.Context, targetIds []string, targetType action.TargetType, userId string) (*dto.BatchGetSharedResp, error) {
+	shared, err := service.ShareMongoMapper.BatchIsShared(ctx, targetIds, targetType, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetSharedResp{Shared: shared}, nil
+}
+
+func (service ShareService) BatchGetSharedCount(ctx context.Context, targetIds []string, targetType action.TargetType) (*dto.BatchGetSharedCountResp, error) {
+	counts, err := batchGetCount(ctx, service.CounterMongoMapper, targetIds, targetType, counter.Share, service.ShareMongoMapper.BatchCountShares)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sharers, err := service.ShareMongoMapper.BatchCountSharers(ctx, targetIds, targetType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BatchGetSharedCountResp{Counts: counts, Sharers: sharers}, nil
+}
+
+func toShares(data []*share.Share) ([]*action.Action_Share, error) {
+	var shares []*action.Action_Share
+	for _, val := range data {
+		aShare := &action.Action_Share{}
+		err := copier.Copy(aShare, val)
+		if err != nil {
+			return nil, err
+		}
+		aShare.Id = val.ID.Hex()
+		aShare.CreateAt = val.CreateAt.Unix()
+		shares = append(shares, aShare)
+	}
+	return shares, nil
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/conversion"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/deadletter"
+	"meowcloud-action/infra/mapper/delivery"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/favorite"
+	"meowcloud-action/infra/mapper/follow"
+	"meowcloud-action/infra/mapper/like"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/recommend"
+	"meowcloud-action/infra/mapper/share"
+)
+
+type ITargetService interface {
+	DeleteTargetActions(ctx context.Context, targetId string, targetType action.TargetType, dryRun bool) (*dto.DeleteTargetActionsResp, error)
+	ReconcileCounts(ctx context.Context, targetId string, targetType action.TargetType) (*dto.ReconcileCountsResp, error)
+}
+
+type TargetService struct {
+	LikeMongoMapper       like.IMongoMapper
+	FollowMongoMapper     follow.IMongoMapper
+	ShareMongoMapper      share.IMongoMapper
+	FavoriteMongoMapper   favorite.IMongoMapper
+	EventMongoMapper      event.IMongoMapper
+	CounterMongoMapper    counter.IMongoMapper
+	ConversionMongoMapper conversion.IMongoMapper
+	RecommendMongoMapper  recommend.IMongoMapper
+	OutboxMongoMapper     outbox.IMongoMapper
+	DeliveryMongoMapper   delivery.IMongoMapper
+	DeadLetterMongoMapper deadletter.IMongoMapper
+}
+
+func NewTargetService() ITargetService {
+	return &TargetService{
+		LikeMongoMapper:       like.NewMongoMapper(),
+		FollowMongoMapper:     follow.NewMongoMapper(),
+		ShareMongoMapper:      share.NewMongoMapper(),
+		FavoriteMongoMapper:   favorite.NewMongoMapper(),
+		EventMongoMapper:      event.NewMongoMapper(),
+		CounterMongoMapper:    counter.NewMongoMapper(),
+		ConversionMongoMapper: conversion.NewMongoMapper(),
+		RecommendMongoMapper:  recommend.NewMongoMapper(),
+		OutboxMongoMapper:     outbox.NewMongoMapper(),
+		DeliveryMongoMapper:   delivery.NewMongoMapper(),
+		DeadLetterMongoMapper: deadletter.NewMongoMapper(),
+	}
+}
+
+// DeleteTargetActions 目标在上游被删除后，物理删除所有指向它的点赞、关注、分享、收藏、分享转化、行为历史、待投递的事件以及它的计数，
+// 并把它从预计算的推荐结果中移除。每一步都只删除剩余的记录，中途失败时可以重新调用
+func (service *TargetService) DeleteTargetActions(ctx context.Context, targetId string, targetType action.TargetType, dryRun bool) (*dto.DeleteTargetActionsResp, error) {
+	if dryRun {
+		return service.countTargetActions(ctx, targetId, targetType)
+	}
+
+	// 先删除计数，中途失败时剩余明细的计数会在读取时重新统计，不会与明细不一致
+	err := service.CounterMongoMapper.Delete(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	likes, err := service.LikeMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := service.FollowMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	// 转化记录只关联分享id，需要在删除分享之前查出
+	shareIds, err := service.ShareMongoMapper.GetIdsByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	conversions, err := service.ConversionMongoMapper.DeleteByShareIds(ctx, shareIds)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := service.ShareMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites, err := service.FavoriteMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := service.EventMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations, err := service.RecommendMongoMapper.PullCandidate(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	outboxMessages, err := service.OutboxMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := service.DeliveryMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters, err := service.DeadLetterMongoMapper.DeleteByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	// 删除过程中的读取可能用尚未删除的明细重新初始化了计数，明细删除完之后再删除一次
+	err = service.CounterMongoMapper.Delete(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.DeleteTargetActionsResp{
+		Likes:             likes,
+		Follows:           follows,
+		Shares:            shares,
+		Favorites:         favorites,
+		Events:            events,
+		Conversions:       conversions,
+		Recommendations:   recommendations,
+		OutboxMessages:    outboxMessages,
+		WebhookDeliveries: deliveries,
+		DeadLetters:       deadLetters,
+	}, nil
+}
+
+func (service *TargetService) countTargetActions(ctx context.Context, targetId string, targetType action.TargetType) (*dto.DeleteTargetActionsResp, error) {
+	likes, err := service.LikeMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := service.FollowMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := service.ShareMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	shareIds, err := service.ShareMongoMapper.GetIdsByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	conversions, err := service.ConversionMongoMapper.CountByShareIds(ctx, shareIds)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites, err := service.FavoriteMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := service.EventMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations, err := service.RecommendMongoMapper.CountByCandidate(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	outboxMessages, err := service.OutboxMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := service.DeliveryMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters, err := service.DeadLetterMongoMapper.CountByTarget(ctx, targetId, targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.DeleteTargetActionsResp{
+		Likes:             likes,
+		Follows:           follows,
+		Shares:            shares,
+		Favorites:         favorites,
+		Events:            events,
+		Conversions:       conversions,
+		Recommendations:   recommendations,
+		OutboxMessages:    outboxMessages,
+		WebhookDeliveries: deliveries,
+		DeadLetters:       deadLetters,
+	}, nil
+}
+
+// ReconcileCounts 从明细重新统计目标的全部计数并覆盖物化计数，用于修复明细被直接修改等原因产生的偏差
+func (service *TargetService) ReconcileCounts(ctx context.Context, targetId string, targetType action.TargetType) (*dto.ReconcileCountsResp, error) {
+	likes, err := reconcileCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Like, service.LikeMongoMapper.CountLikes)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := reconcileCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Follow, service.FollowMongoMapper.CountFollows)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := reconcileCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Share, service.ShareMongoMapper.CountShares)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites, err := reconcileCount(ctx, service.CounterMongoMapper, targetId, targetType, counter.Favorite, service.FavoriteMongoMapper.CountFavorites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ReconcileCountsResp{
+		Likes:     likes,
+		Follows:   follows,
+		Shares:    shares,
+		Favorites: favorites,
+	}, nil
+}
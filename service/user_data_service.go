@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/block"
+	"meowcloud-action/infra/mapper/collection"
+	"meowcloud-action/infra/mapper/conversion"
+	"meowcloud-action/infra/mapper/counter"
+	"meowcloud-action/infra/mapper/deadletter"
+	"meowcloud-action/infra/mapper/delivery"
+	"meowcloud-action/infra/mapper/event"
+	"meowcloud-action/infra/mapper/favorite"
+	"meowcloud-action/infra/mapper/follow"
+	"meowcloud-action/infra/mapper/like"
+	"meowcloud-action/infra/mapper/outbox"
+	"meowcloud-action/infra/mapper/privacy"
+	"meowcloud-action/infra/mapper/recommend"
+	"meowcloud-action/infra/mapper/share"
+)
+
+type IUserDataService interface {
+	EraseUserActions(ctx context.Context, userId string) (*dto.EraseUserActionsResp, error)
+	ExportUserActions(ctx context.Context, userId string, format string, token string, limit int64, w io.Writer) (string, error)
+}
+
+type UserDataService struct {
+	LikeMongoMapper    like.IMongoMapper
+	FollowMongoMapper  follow.IMongoMapper
+	ShareMongoMapper   share.IMongoMapper
+	CounterMongoMapper counter.IMongoMapper
+	EventMongoMapper   event.IMongoMapper
+	// 推荐结果由关注关系计算得到，随用户数据一起删除
+	RecommendMongoMapper  recommend.IMongoMapper
+	BlockMongoMapper      block.IMongoMapper
+	PrivacyMongoMapper    privacy.IMongoMapper
+	ConversionMongoMapper conversion.IMongoMapper
+	FavoriteMongoMapper   favorite.IMongoMapper
+	CollectionMongoMapper collection.IMongoMapper
+	OutboxMongoMapper     outbox.IMongoMapper
+	DeliveryMongoMapper   delivery.IMongoMapper
+	DeadLetterMongoMapper deadletter.IMongoMapper
+	// 其他用户指向该用户的行为按删除目标处理
+	TargetService ITargetService
+}
+
+func NewUserDataService() IUserDataService {
+	return &UserDataService{
+		LikeMongoMapper:       like.NewMongoMapper(),
+		FollowMongoMapper:     follow.NewMongoMapper(),
+		ShareMongoMapper:      share.NewMongoMapper(),
+		CounterMongoMapper:    counter.NewMongoMapper(),
+		EventMongoMapper:      event.NewMongoMapper(),
+		RecommendMongoMapper:  recommend.NewMongoMapper(),
+		BlockMongoMapper:      block.NewMongoMapper(),
+		PrivacyMongoMapper:    privacy.NewMongoMapper(),
+		ConversionMongoMapper: conversion.NewMongoMapper(),
+		FavoriteMongoMapper:   favorite.NewMongoMapper(),
+		CollectionMongoMapper: collection.NewMongoMapper(),
+		OutboxMongoMapper:     outbox.NewMongoMapper(),
+		DeliveryMongoMapper:   delivery.NewMongoMapper(),
+		DeadLetterMongoMapper: deadletter.NewMongoMapper(),
+		TargetService:         NewTargetService(),
+	}
+}
+
+type target struct {
+	TargetId   string
+	TargetType action.TargetType
+}
+
+// EraseUserActions 物理删除用户的全部点赞、关注、分享、收藏、事件、转化和待投递的消息，并扣减相应目标的计数，
+// 其他用户对该用户的关注、关注请求等行为按删除目标处理。
+// 每一步都只删除剩余的记录，中途失败时可以重新调用，已删除的记录不会重复扣减计数
+func (service *UserDataService) EraseUserActions(ctx context.Context, userId string) (*dto.EraseUserActionsResp, error) {
+	received, err := service.TargetService.DeleteTargetActions(ctx, userId, action.TargetType_USER, false)
+	if err != nil {
+		return nil, err
+	}
+
+	likes, err := eraseInBatches(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, counter.Like, userId, service.LikeMongoMapper.DeleteByUserId, func(val *like.Like) (target, bool) {
+		return target{val.TargetId, val.TargetType}, !val.IsCancel
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := eraseInBatches(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, counter.Follow, userId, service.FollowMongoMapper.DeleteByUserId, func(val *follow.Follow) (target, bool) {
+		return target{val.TargetId, val.TargetType}, !val.IsCancel
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := eraseInBatches(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, counter.Share, userId, service.ShareMongoMapper.DeleteByUserId, func(val *share.Share) (target, bool) {
+		return target{val.TargetId, val.TargetType}, true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	favorites, err := eraseInBatches(ctx, service.OutboxMongoMapper, service.CounterMongoMapper, counter.Favorite, userId, service.FavoriteMongoMapper.DeleteByUserId, func(val *favorite.Favorite) (target, bool) {
+		return target{val.TargetId, val.TargetType}, !val.IsCancel
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = service.CollectionMongoMapper.DeleteByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	events, err := service.EventMongoMapper.DeleteByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = service.RecommendMongoMapper.DeleteByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	if _, err = service.BlockMongoMapper.DeleteByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	if _, err = service.PrivacyMongoMapper.DeleteByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	conversions, err := service.ConversionMongoMapper.DeleteByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	outboxMessages, err := service.OutboxMongoMapper.DeleteByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := service.DeliveryMongoMapper.DeleteByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters, err := service.DeadLetterMongoMapper.DeleteByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.EraseUserActionsResp{
+		Likes:             likes,
+		Follows:           follows,
+		Shares:            shares,
+		Favorites:         favorites,
+		Events:            events,
+		Conversions:       conversions,
+		OutboxMessages:    outboxMessages,
+		WebhookDeliveries: deliveries,
+		DeadLetters:       deadLetters,
+		Received:          received,
+	}, nil
+}
+
+// eraseBatchSize 每个事务中删除的记录数，避免一次把用户的全部记录读入内存或事务过大
+const eraseBatchSize = int64(500)
+
+// eraseInBatches 按_id顺序分批删除用户的记录，每批的删除和对应目标计数的扣减在同一个事务中完成，
+// countOf返回记录所属的目标以及该记录是否计入了计数。返回删除的记录数
+func eraseInBatches[T any](ctx context.Context, outboxMapper outbox.IMongoMapper, counterMapper counter.IMongoMapper, kind counter.Kind, userId string,
+	deleteBatch func(ctx context.Context, userId string, limit int64) ([]*T, error), countOf func(*T) (target, bool)) (int64, error) {
+	var total int64
+	for {
+		var n int64
+		err := outboxMapper.Transaction(ctx, func(ctx context.Context) error {
+			rows, err := deleteBatch(ctx, userId, eraseBatchSize)
+			if err != nil {
+				return err
+			}
+			n = int64(len(rows))
+
+			counts := make(map[target]int64)
+			for _, row := range rows {
+				if t, ok := countOf(row); ok {
+					counts[t]++
+				}
+			}
+			for t, delta := range counts {
+				if err = counterMapper.Incr(ctx, t.TargetId, t.TargetType, kind, -delta); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if n < eraseBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// ExportUserActions 把用户的点赞、关注、分享、收藏（包括已取消的）逐条写入w，format为jsonl或csv。
+// 每次最多写入limit条，从token处继续，返回下一段的token，为空表示已经导出完毕
+func (service *UserDataService) ExportUserActions(ctx context.Context, userId string, format string, token string, limit int64, w io.Writer) (string, error) {
+	t, err := decodeExportToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	// csv的表头只写在第一段
+	writer, err := newRecordWriter(format, w, token == "")
+	if err != nil {
+		return "", err
+	}
+
+	sources := service.exportSources()
+	var written int64
+	for t.Source < len(sources) && written < limit {
+		remaining := limit - written
+		var n int64
+		err = sources[t.Source](ctx, userId, t.LastId, remaining, func(id primitive.ObjectID, record *dto.ActionRecord) error {
+			n++
+			t.LastId = id
+			return writer.Write(record)
+		})
+		if err != nil {
+			return "", err
+		}
+		written += n
+
+		// 不足remaining条说明当前集合已经导出完毕
+		if n < remaining {
+			t = exportToken{Source: t.Source + 1}
+		}
+	}
+
+	if err = writer.Flush(); err != nil {
+		return "", err
+	}
+
+	if t.Source >= len(sources) {
+		return "", nil
+	}
+	return encodeExportToken(t)
+}
+
+// exportSource 按_id顺序遍历一个集合中用户afterId之后的最多limit条记录
+type exportSource func(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, write func(id primitive.ObjectID, record *dto.ActionRecord) error) error
+
+// exportSources 导出的集合及其顺序，token中记录的是下标，调整顺序会使已发出的token失效
+func (service *UserDataService) exportSources() []exportSource {
+	return []exportSource{
+		func(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, write func(primitive.ObjectID, *dto.ActionRecord) error) error {
+			return service.LikeMongoMapper.IterateByUserId(ctx, userId, afterId, limit, func(val *like.Like) error {
+				return write(val.ID, &dto.ActionRecord{
+					Action:     string(event.Like),
+					TargetId:   val.TargetId,
+					TargetType: val.TargetType,
+					UserId:     val.UserId,
+					IsCancel:   val.IsCancel,
+					CreateAt:   unix(val.CreateAt),
+					UpdateAt:   unix(val.UpdateAt),
+					DeleteAt:   unix(val.DeleteAt),
+					Reaction:   val.Reaction,
+				})
+			})
+		},
+		func(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, write func(primitive.ObjectID, *dto.ActionRecord) error) error {
+			return service.FollowMongoMapper.IterateByUserId(ctx, userId, afterId, limit, func(val *follow.Follow) error {
+				return write(val.ID, &dto.ActionRecord{
+					Action:     string(event.Follow),
+					TargetId:   val.TargetId,
+					TargetType: val.TargetType,
+					UserId:     val.UserId,
+					IsCancel:   val.IsCancel,
+					CreateAt:   unix(val.CreateAt),
+					UpdateAt:   unix(val.UpdateAt),
+					DeleteAt:   unix(val.DeleteAt),
+				})
+			})
+		},
+		func(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, write func(primitive.ObjectID, *dto.ActionRecord) error) error {
+			return service.ShareMongoMapper.IterateByUserId(ctx, userId, afterId, limit, func(val *share.Share) error {
+				return write(val.ID, &dto.ActionRecord{
+					Action:     string(event.Share),
+					TargetId:   val.TargetId,
+					TargetType: val.TargetType,
+					UserId:     val.UserId,
+					CreateAt:   unix(val.CreateAt),
+					UpdateAt:   unix(val.UpdateAt),
+					DeleteAt:   unix(val.DeleteAt),
+					Channel:    val.Channel,
+				})
+			})
+		},
+		func(ctx context.Context, userId string, afterId primitive.ObjectID, limit int64, write func(primitive.ObjectID, *dto.ActionRecord) error) error {
+			return service.FavoriteMongoMapper.IterateByUserId(ctx, userId, afterId, limit, func(val *favorite.Favorite) error {
+				return write(val.ID, &dto.ActionRecord{
+					Action:     string(event.Favorite),
+					TargetId:   val.TargetId,
+					TargetType: val.TargetType,
+					UserId:     val.UserId,
+					IsCancel:   val.IsCancel,
+					CreateAt:   unix(val.CreateAt),
+					UpdateAt:   unix(val.UpdateAt),
+					DeleteAt:   unix(val.DeleteAt),
+				})
+			})
+		},
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/basic"
+	"github.com/xh-polaris/service-idl-gen-go/kitex_gen/meowcloud/action"
+	"meowcloud-action/common/consts"
+	"meowcloud-action/common/dto"
+	"meowcloud-action/infra/mapper/deadletter"
+	"meowcloud-action/infra/mapper/delivery"
+	"meowcloud-action/infra/mapper/pagination"
+	"meowcloud-action/infra/mapper/webhook"
+	"net/url"
+)
+
+type IWebhookService interface {
+	CreateWebhook(ctx context.Context, rawURL string, topics []string, targetTypes []action.TargetType) (*dto.CreateWebhookResp, error)
+	DeleteWebhook(ctx context.Context, id string) (*dto.DeleteWebhookResp, error)
+	ListWebhooks(ctx context.Context) (*dto.ListWebhooksResp, error)
+	ListDeadLetters(ctx context.Context, options *basic.PaginationOptions) (*dto.ListDeadLettersResp, error)
+	ReplayDeadLetter(ctx context.Context, id string) (*dto.ReplayDeadLetterResp, error)
+}
+
+type WebhookService struct {
+	WebhookMongoMapper    webhook.IMongoMapper
+	DeliveryMongoMapper   delivery.IMongoMapper
+	DeadLetterMongoMapper deadletter.IMongoMapper
+}
+
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		WebhookMongoMapper:    webhook.NewMongoMapper(),
+		DeliveryMongoMapper:   delivery.NewMongoMapper(),
+		DeadLetterMongoMapper: deadletter.NewMongoMapper(),
+	}
+}
+
+func (service *WebhookService) CreateWebhook(ctx context.Context, rawURL string, topics []string, targetTypes []action.TargetType) (*dto.CreateWebhookResp, error) {
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, consts.InvalidWebhookURL
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := service.WebhookMongoMapper.InsertOne(ctx, rawURL, topics, targetTypes, secret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreateWebhookResp{
+		Webhook: toWebhook(data),
+		Secret:  secret,
+	}, nil
+}
+
+// DeleteWebhook 已入队的投递会在投递时发现回调不存在而被丢弃
+func (service *WebhookService) DeleteWebhook(ctx context.Context, id string) (*dto.DeleteWebhookResp, error) {
+
+	data, err := service.WebhookMongoMapper.FindOne(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, consts.WebhookNotExist
+	}
+
+	err = service.WebhookMongoMapper.DeleteOne(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.DeleteWebhookResp{}, nil
+}
+
+func (service *WebhookService) ListWebhooks(ctx context.Context) (*dto.ListWebhooksResp, error) {
+
+	data, err := service.WebhookMongoMapper.FindAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]*dto.Webhook, 0, len(data))
+	for _, val := range data {
+		webhooks = append(webhooks, toWebhook(val))
+	}
+
+	return &dto.ListWebhooksResp{
+		Webhooks: webhooks,
+	}, nil
+}
+
+func (service *WebhookService) ListDeadLetters(ctx context.Context, options *basic.PaginationOptions) (*dto.ListDeadLettersResp, error) {
+	data, lastToken, err := service.DeadLetterMongoMapper.GetDeadLetters(ctx, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := make([]*dto.DeadLetter, 0, len(data))
+	for _, val := range data {
+		deadLetters = append(deadLetters, &dto.DeadLetter{
+			Id:        val.ID.Hex(),
+			WebhookId: val.WebhookId,
+			EventId:   val.EventId,
+			Topic:     val.Topic,
+			Payload:   val.Payload,
+			Attempts:  val.Attempts,
+			LastError: val.LastError,
+			CreateAt:  unix(val.CreateAt),
+		})
+	}
+
+	return &dto.ListDeadLettersResp{
+		DeadLetters: deadLetters,
+		Token:       lastToken,
+		HasMore:     pagination.HasMore(lastToken),
+	}, nil
+}
+
+// ReplayDeadLetter 先重新入队再删除死信，中途失败时死信仍在，可以再次重放
+func (service *WebhookService) ReplayDeadLetter(ctx context.Context, id string) (*dto.ReplayDeadLetterResp, error) {
+
+	data, err := service.DeadLetterMongoMapper.FindOne(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, consts.DeadLetterNotExist
+	}
+
+	err = service.DeliveryMongoMapper.InsertOne(ctx, &delivery.Delivery{
+		WebhookId:  data.WebhookId,
+		EventId:    data.EventId,
+		Topic:      data.Topic,
+		Payload:    data.Payload,
+		TargetId:   data.TargetId,
+		TargetType: data.TargetType,
+		UserId:     data.UserId,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.DeadLetterMongoMapper.DeleteOne(ctx, data.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ReplayDeadLetterResp{}, nil
+}
+
+// Publish 实现mq.Publisher，把outbox投递的事件按订阅条件拆成每个回调的投递任务
+func (service *WebhookService) Publish(ctx context.Context, topic string, payload []byte) error {
+
+	var domainEvent DomainEvent
+	if err := json.Unmarshal(payload, &domainEvent); err != nil {
+		return err
+	}
+
+	webhooks, err := service.WebhookMongoMapper.FindMatched(ctx, topic, domainEvent.Event.TargetType)
+
+	if err != nil {
+		return err
+	}
+
+	for _, val := range webhooks {
+		err = service.DeliveryMongoMapper.InsertOne(ctx, &delivery.Delivery{
+			WebhookId:  val.ID.Hex(),
+			EventId:    domainEvent.Id,
+			Topic:      topic,
+			Payload:    string(payload),
+			TargetId:   domainEvent.Event.TargetId,
+			TargetType: domainEvent.Event.TargetType,
+			UserId:     domainEvent.Event.UserId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func toWebhook(data *webhook.Webhook) *dto.Webhook {
+	return &dto.Webhook{
+		Id:          data.ID.Hex(),
+		URL:         data.URL,
+		Topics:      data.Topics,
+		TargetTypes: data.TargetTypes,
+		CreateAt:    unix(data.CreateAt),
+	}
+}
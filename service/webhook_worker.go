@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/xh-polaris/gopkg/util/log"
+	"io"
+	"meowcloud-action/common/config"
+	"meowcloud-action/infra/mapper/deadletter"
+	"meowcloud-action/infra/mapper/delivery"
+	"meowcloud-action/infra/mapper/webhook"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Meowcloud-Signature"
+	HeaderTimestamp = "X-Meowcloud-Timestamp"
+	HeaderEvent     = "X-Meowcloud-Event"
+	HeaderDelivery  = "X-Meowcloud-Delivery"
+)
+
+// Sign 计算回调签名，接收方用同样的方式对"timestamp.body"计算HMAC-SHA256并比较
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookWorker 定期领取到期的投递任务并回调，失败后指数退避重试，超过最大次数转入死信
+type WebhookWorker struct {
+	WebhookMongoMapper    webhook.IMongoMapper
+	DeliveryMongoMapper   delivery.IMongoMapper
+	DeadLetterMongoMapper deadletter.IMongoMapper
+	client                *http.Client
+	maxAttempts           int64
+	backoff               time.Duration
+	maxBackoff            time.Duration
+	batchSize             int64
+	interval              time.Duration
+	done                  chan struct{}
+}
+
+func NewWebhookWorker() *WebhookWorker {
+	aConfig := config.Get()
+	return &WebhookWorker{
+		WebhookMongoMapper:    webhook.NewMongoMapper(),
+		DeliveryMongoMapper:   delivery.NewMongoMapper(),
+		DeadLetterMongoMapper: deadletter.NewMongoMapper(),
+		client:                &http.Client{Timeout: aConfig.Webhook.Timeout},
+		maxAttempts:           aConfig.Webhook.MaxAttempts,
+		backoff:               aConfig.Webhook.Backoff,
+		maxBackoff:            aConfig.Webhook.MaxBackoff,
+		batchSize:             aConfig.Webhook.BatchSize,
+		interval:              aConfig.Webhook.Interval,
+		done:                  make(chan struct{}),
+	}
+}
+
+// Start 阻塞运行直到Stop被调用
+func (w *WebhookWorker) Start() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.work(context.Background())
+		}
+	}
+}
+
+func (w *WebhookWorker) Stop() {
+	close(w.done)
+}
+
+func (w *WebhookWorker) work(ctx context.Context) {
+	for i := int64(0); i < w.batchSize; i++ {
+		// 领取时把下次尝试时间推迟到请求超时之后，避免被其他实例重复领取
+		d, err := w.DeliveryMongoMapper.ClaimDue(ctx, 2*w.client.Timeout)
+		if err != nil {
+			log.CtxError(ctx, "领取webhook投递失败: %v", err)
+			return
+		}
+		// 没有到期的投递
+		if d == nil {
+			return
+		}
+		w.deliver(ctx, d)
+	}
+}
+
+func (w *WebhookWorker) deliver(ctx context.Context, d *delivery.Delivery) {
+	hook, err := w.WebhookMongoMapper.FindOne(ctx, d.WebhookId)
+	if err != nil {
+		log.CtxError(ctx, "查询webhook %s失败: %v", d.WebhookId, err)
+		return
+	}
+
+	// 回调已被删除，丢弃投递
+	if hook == nil {
+		w.remove(ctx, d)
+		return
+	}
+
+	if err = w.post(ctx, hook, d); err == nil {
+		w.remove(ctx, d)
+		return
+	}
+
+	if d.Attempts >= w.maxAttempts {
+		log.CtxError(ctx, "webhook投递%s重试%d次仍失败，转入死信: %v", d.ID.Hex(), d.Attempts, err)
+		w.bury(ctx, d, err)
+		return
+	}
+
+	if err = w.DeliveryMongoMapper.Retry(ctx, d.ID, time.Now().Add(w.nextBackoff(d.Attempts)), err); err != nil {
+		log.CtxError(ctx, "记录webhook投递%s失败原因失败: %v", d.ID.Hex(), err)
+	}
+}
+
+func (w *WebhookWorker) post(ctx context.Context, hook *webhook.Webhook, d *delivery.Delivery) error {
+	body := []byte(d.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderEvent, d.Topic)
+	// 同一事件的多次重试使用相同的id，接收方可据此去重
+	req.Header.Set(HeaderDelivery, d.EventId)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("回调返回状态码%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// nextBackoff 第attempts次失败后的等待时间
+func (w *WebhookWorker) nextBackoff(attempts int64) time.Duration {
+	backoff := w.backoff
+	for i := int64(1); i < attempts && backoff < w.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > w.maxBackoff {
+		backoff = w.maxBackoff
+	}
+	return backoff
+}
+
+func (w *WebhookWorker) bury(ctx context.Context, d *delivery.Delivery, cause error) {
+	err := w.DeadLetterMongoMapper.InsertOne(ctx, &deadletter.DeadLetter{
+		WebhookId:  d.WebhookId,
+		EventId:    d.EventId,
+		Topic:      d.Topic,
+		Payload:    d.Payload,
+		Attempts:   d.Attempts,
+		LastError:  cause.Error(),
+		TargetId:   d.TargetId,
+		TargetType: d.TargetType,
+		UserId:     d.UserId,
+	})
+	if err != nil {
+		log.CtxError(ctx, "写入webhook死信失败: %v", err)
+		return
+	}
+	w.remove(ctx, d)
+}
+
+func (w *WebhookWorker) remove(ctx context.Context, d *delivery.Delivery) {
+	if err := w.DeliveryMongoMapper.DeleteOne(ctx, d.ID); err != nil {
+		log.CtxError(ctx, "删除webhook投递%s失败: %v", d.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{"普通请求", "secret", 1700000000, `{"id":"1"}`, "sha256=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"},
+		{"空密钥和空请求体", "", 0, "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignCoversAllInputs(t *testing.T) {
+	base := Sign("secret", 1700000000, []byte(`{"id":"1"}`))
+	others := map[string]string{
+		"密钥不同":  Sign("other", 1700000000, []byte(`{"id":"1"}`)),
+		"时间戳不同": Sign("secret", 1700000001, []byte(`{"id":"1"}`)),
+		"请求体不同": Sign("secret", 1700000000, []byte(`{"id":"2"}`)),
+	}
+	for name, got := range others {
+		if got == base {
+			t.Errorf("%s时签名不应相同", name)
+		}
+	}
+}